@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -24,10 +27,24 @@ var (
 	rtpbufferDump    string
 	receiverCodec    string
 	// savePath         string // declared in send.go
-	receiverQLOGDir string
-	sink            string
-	rfc8888         bool
-	twcc            bool
+	receiverQLOGDir  string
+	sink             string
+	rfc8888          bool
+	rfc8888Format    string
+	rfc8888Interval  time.Duration
+	rfc8888Dump      string
+	twcc             bool
+	receiverReports  bool
+	remb             bool
+	keyframeRequest  string
+	keyframeDelay    time.Duration
+	nack             bool
+	receiverFEC      bool
+	receiverSRTPKey  string
+	receiverDTLSSRTP bool
+	receiverTLS      rtc.TLSConfig
+	resumeSinks      bool
+	multipath        bool
 )
 
 func init() {
@@ -46,7 +63,24 @@ func init() {
 	receiveCmd.Flags().StringVar(&rtpbufferDump, "rtpbuffer-dump", "", "RTPjitterbuffer dump file")
 	receiveCmd.Flags().StringVar(&receiverQLOGDir, "qlog", "", "QLOG directory. No logs if empty. Use 'sdtout' for Stdout or '<directory>' for a QLOG file named '<directory>/<connection-id>.qlog'")
 	receiveCmd.Flags().BoolVarP(&rfc8888, "rfc8888", "r", false, "Send RTCP Feedback for congestion control (RFC 8888)")
+	receiveCmd.Flags().StringVar(&rfc8888Format, "rfc8888-format", "draft", "Format of RFC 8888 feedback: draft, as expected by SCReAM, or rfc8888")
+	receiveCmd.Flags().DurationVar(&rfc8888Interval, "rfc8888-interval", rtc.DefaultCCFBInterval, "How often RFC 8888 feedback is sent")
+	receiveCmd.Flags().StringVar(&rfc8888Dump, "rfc8888-dump", "", "RFC 8888 feedback log file, use 'stdout' for Stdout")
 	receiveCmd.Flags().BoolVarP(&twcc, "twcc", "t", false, "Send RTCP transport wide congestion control feedback")
+	receiveCmd.Flags().BoolVar(&remb, "remb", false, "Estimate the bandwidth on the receiver and send it in RTCP REMB packets, use with 'send --cc remb'")
+	receiveCmd.Flags().StringVar(&keyframeRequest, "keyframe-request", "none", "Ask the sender for a keyframe after losses: none, pli or fir")
+	receiveCmd.Flags().DurationVar(&keyframeDelay, "keyframe-request-delay", rtc.DefaultKeyframeRequestDelay, "How long a missing packet may be late before a keyframe is requested")
+	receiveCmd.Flags().BoolVar(&nack, "nack", false, "Send NACKs for missing packets, use with 'send --rtx'")
+	receiveCmd.Flags().BoolVar(&receiverFEC, "fec", false, "Recover lost packets from FEC packets, use with 'send --fec'")
+	receiveCmd.Flags().BoolVar(&receiverReports, "rtcp-reports", false, "Send RTCP receiver reports, required for RTT measurements with --transport udp")
+	receiveCmd.Flags().StringVar(&receiverSRTPKey, "srtp-key", "", "Hex encoded SRTP master key and salt (30 bytes) to decrypt RTP and RTCP, only when --transport is udp or tcp")
+	receiveCmd.Flags().BoolVar(&resumeSinks, "resume-sinks", false, "Keep sinks open until exiting, so that senders reconnecting with --reconnect continue writing to them")
+	receiveCmd.Flags().BoolVar(&multipath, "multipath", false, "Merge the connections of senders using --path")
+	receiveCmd.Flags().StringVar(&receiverTLS.CertFile, "tls-cert", "", "PEM encoded TLS certificate chain, a self-signed certificate is generated if empty, only when --transport is quic or with --dtls-srtp")
+	receiveCmd.Flags().StringVar(&receiverTLS.KeyFile, "tls-key", "", "PEM encoded private key of --tls-cert")
+	receiveCmd.Flags().StringVar(&receiverTLS.CAFile, "tls-client-ca", "", "PEM encoded CA certificates, require senders to present a client certificate issued by one of them")
+	receiveCmd.Flags().StringSliceVar(&receiverTLS.Pins, "tls-client-pin", nil, "SHA-256 fingerprints of accepted sender certificates, require senders to present one of them")
+	receiveCmd.Flags().BoolVar(&receiverDTLSSRTP, "dtls-srtp", false, "Decrypt RTP and RTCP using SRTP with keys negotiated by DTLS, only when --transport is udp or tcp")
 }
 
 var receiveCmd = &cobra.Command{
@@ -83,12 +117,51 @@ func startReceiver() error {
 	}
 	defer rtpbufferDumpfile.Close()
 
+	rfc8888DumpFile, err := getLogFile(rfc8888Dump)
+	if err != nil {
+		return err
+	}
+	defer rfc8888DumpFile.Close()
+
+	format, err := rtc.ParseCCFBFormat(rfc8888Format)
+	if err != nil {
+		return err
+	}
+	request, err := rtc.ParseKeyframeRequest(keyframeRequest)
+	if err != nil {
+		return err
+	}
+
 	c := rtc.ReceiverConfig{
-		RTPDump:  rtpDumpFile,
-		RTCPDump: rtcpDumpfile,
-		RFC8888:  rfc8888,
-		TWCC:     twcc,
+		RTPDump:         rtpDumpFile,
+		RTCPDump:        rtcpDumpfile,
+		RFC8888:         rfc8888,
+		RFC8888Format:   format,
+		RFC8888Interval: rfc8888Interval,
+		RFC8888Dump:     rfc8888DumpFile,
+		TWCC:            twcc,
+		RTCPReports:     receiverReports,
+		REMB:            remb,
+
+		KeyframeRequest:      request,
+		KeyframeRequestDelay: keyframeDelay,
+		NACK:                 nack,
+		FEC:                  receiverFEC,
 	}
+	c.SRTP, err = srtpConfig(receiveTransport, receiverSRTPKey, receiverDTLSSRTP, func() (*tls.Config, error) {
+		return rtc.NewServerTLSConfig(receiverTLS)
+	})
+	if err != nil {
+		return err
+	}
+	if len(receiverSRTPKey) > 0 && multipath {
+		return fmt.Errorf("--srtp-key can't be used with --multipath, the paths don't share a session nonce")
+	}
+	if resumeSinks {
+		c.Sinks = rtc.NewSinkPool()
+		defer c.Sinks.Close()
+	}
+	c.Multipath = multipath
 
 	receiverFactory, err := rtc.GstreamerReceiverFactory(c)
 	if err != nil {
@@ -99,7 +172,7 @@ func startReceiver() error {
 		return err
 	}
 
-	var mediaSink rtc.MediaSinkFactory = func() (rtc.MediaSink, error) {
+	var mediaSink rtc.MediaSinkFactory = func(uint64) (rtc.MediaSink, error) {
 		return nopCloser{io.Discard}, nil
 	}
 	if receiverCodec != "syncodec" {
@@ -112,7 +185,11 @@ func startReceiver() error {
 
 	switch receiveTransport {
 	case "quic":
-		server, err := rtc.NewServer(receiverFactory, receiveAddr, mediaSink, tracer)
+		tlsConf, err := rtc.NewServerTLSConfig(receiverTLS)
+		if err != nil {
+			return err
+		}
+		server, err := rtc.NewServer(receiverFactory, receiveAddr, mediaSink, tracer, tlsConf)
 		if err != nil {
 			return err
 		}
@@ -171,8 +248,12 @@ func gstSinkFactory(codec string, sink string, fps io.Writer, rtpbuffer io.Write
 	} else {
 		dst = "clocksync ! autovideosink"
 	}
-	return func() (rtc.MediaSink, error) {
-		dstPipeline, err := gstsink.NewPipeline(codec, dst, savePath)
+	return func(flowID uint64) (rtc.MediaSink, error) {
+		dst := dst
+		if sink != "fpsdisplaysink" && sink != "fakesink" && sink != "autovideosink" {
+			dst = fmt.Sprintf("clocksync ! y4menc ! filesink location=%v", flowPath(sink, flowID))
+		}
+		dstPipeline, err := gstsink.NewPipeline(codec, dst, flowPath(savePath, flowID))
 		if err != nil {
 			return nil, err
 		}
@@ -217,11 +298,22 @@ type nopCloser struct {
 func (nopCloser) Close() error { return nil }
 
 func discardingSinkFactory() rtc.MediaSinkFactory {
-	return func() (rtc.MediaSink, error) {
+	return func(uint64) (rtc.MediaSink, error) {
 		return nopCloser{io.Discard}, nil
 	}
 }
 
+// flowPath keeps path for flow 0 and inserts the flow ID before the extension
+// for all other flows, so that multiple flows don't overwrite each other's
+// files.
+func flowPath(path string, flowID uint64) string {
+	if len(path) == 0 || flowID == 0 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%v-%v%v", strings.TrimSuffix(path, ext), flowID, ext)
+}
+
 func getLogFile(file string) (io.WriteCloser, error) {
 	if len(file) == 0 {
 		return nopCloser{io.Discard}, nil
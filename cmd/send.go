@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"encoding/binary"
 	"fmt"
@@ -10,7 +11,9 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -29,17 +32,40 @@ var (
 	senderRTPDump  string
 	senderRTCPDump string
 	senderCodec    string
-	source         string
+	sources        []string
 	savePath       string
 	ccDump         string
 	senderQLOGDir  string
 	tcpCongAlg     string
 	scream         bool
 	gcc            bool
+	nada           bool
 	newReno        bool
 	sendStream     bool
 	localRFC8888   bool
+	localTWCC      bool
 	initialBitrate uint
+	roqMapping     string
+	streamReset    time.Duration
+	senderReports  bool
+	senderSRTPKey  string
+	senderDTLSSRTP bool
+	senderTLS      rtc.TLSConfig
+	reconnect      bool
+	sendPaths      []string
+	pathScheduler  string
+	pathWeights    []uint
+	pathDump       string
+	failover       []string
+	ccName         string
+	ccInterval     time.Duration
+	ccOnFeedback   bool
+	rateSchedule   string
+	sendECN        string
+	rtx            bool
+	rtxDelay       time.Duration
+	senderFEC      bool
+	fecOverhead    float64
 )
 
 func init() {
@@ -50,7 +76,7 @@ func init() {
 	sendCmd.Flags().StringVar(&sendTransport, "transport", "quic", "Transport protocol to use: quic, udp or tcp")
 	sendCmd.Flags().StringVarP(&sendAddr, "addr", "a", ":4242", "QUIC server address")
 	sendCmd.Flags().StringVarP(&senderCodec, "codec", "c", "h264", "Media codec")
-	sendCmd.Flags().StringVar(&source, "source", "videotestsrc", "Media source")
+	sendCmd.Flags().StringArrayVar(&sources, "source", []string{"videotestsrc"}, "Media source, repeat to send several flows, optionally followed by ',weight=N', ',priority=N', ',min=bps' and ',max=bps' to share the target bitrate")
 	sendCmd.Flags().StringVar(&savePath, "save", "", "Save outgoing video to file")
 	sendCmd.Flags().StringVar(&senderRTPDump, "rtp-dump", "", "RTP dump file, 'stdout' for Stdout")
 	sendCmd.Flags().StringVar(&senderRTCPDump, "rtcp-dump", "", "RTCP dump file, 'stdout' for Stdout")
@@ -58,11 +84,37 @@ func init() {
 	sendCmd.Flags().StringVar(&senderQLOGDir, "qlog", "", "QLOG directory. No logs if empty. Use 'sdtout' for Stdout or '<directory>' for a QLOG file named '<directory>/<connection-id>.qlog'")
 	sendCmd.Flags().StringVar(&tcpCongAlg, "tcp-congestion", "reno", "TCP Congestion control algorithm to use, only when --transport is tcp")
 	sendCmd.Flags().BoolVarP(&scream, "scream", "s", false, "Use SCReAM")
+	sendCmd.Flags().StringVar(&ccName, "cc", "", fmt.Sprintf("Rate controller to use: %v, the bitrate is static if empty", strings.Join(rtc.RateControllers(), ", ")))
+	sendCmd.Flags().DurationVar(&ccInterval, "cc-interval", rtc.DefaultRateControlInterval, "How often the target bitrate of the rate controller is applied")
+	sendCmd.Flags().BoolVar(&ccOnFeedback, "cc-on-feedback", false, "Also apply the target bitrate of the rate controller whenever feedback arrives")
+	sendCmd.Flags().StringVar(&rateSchedule, "rate-schedule", "", "CSV file of time offsets and bitrates to follow instead of a rate controller")
 	sendCmd.Flags().BoolVar(&localRFC8888, "local-rfc8888", false, "Generate local RFC 8888 feedback")
+	sendCmd.Flags().BoolVar(&localTWCC, "local-twcc", false, "Generate local TWCC feedback from QUIC acknowledgments, e.g. for GCC without receiver feedback, only when --transport is quic and --roq-mapping is datagram")
 	sendCmd.Flags().BoolVarP(&gcc, "gcc", "g", false, "Use Google Congestion Control")
+	sendCmd.Flags().BoolVar(&nada, "nada", false, "Use NADA, requires receiving with --twcc or --rfc8888")
 	sendCmd.Flags().BoolVarP(&newReno, "newreno", "n", false, "Enable NewReno Congestion Control")
 	sendCmd.Flags().BoolVar(&sendStream, "stream", false, "Send random data on a stream")
 	sendCmd.Flags().UintVarP(&initialBitrate, "init-rate", "b", 1_000_000, "The initial video bitrate in bps")
+	sendCmd.Flags().StringVar(&roqMapping, "roq-mapping", "datagram", "RTP over QUIC mapping: datagram, stream-per-frame or stream-per-flow, only when --transport is quic")
+	sendCmd.Flags().BoolVar(&senderReports, "rtcp-reports", false, "Send RTCP sender reports, required for RTT measurements with --transport udp")
+	sendCmd.Flags().StringVar(&senderTLS.CertFile, "tls-cert", "", "PEM encoded TLS client certificate chain, only when --transport is quic or with --dtls-srtp")
+	sendCmd.Flags().StringVar(&senderTLS.KeyFile, "tls-key", "", "PEM encoded private key of --tls-cert")
+	sendCmd.Flags().StringVar(&senderTLS.CAFile, "tls-ca", "", "PEM encoded CA certificates to verify the receiver's certificate, which must be issued for the host of --addr, the certificate is not verified if neither --tls-ca nor --tls-pin is set, --dtls-srtp requires one of them")
+	sendCmd.Flags().StringSliceVar(&senderTLS.Pins, "tls-pin", nil, "SHA-256 fingerprints of accepted receiver certificates")
+	sendCmd.Flags().StringVar(&senderSRTPKey, "srtp-key", "", "Hex encoded SRTP master key and salt (30 bytes) to encrypt RTP and RTCP, only when --transport is udp or tcp")
+	sendCmd.Flags().BoolVar(&senderDTLSSRTP, "dtls-srtp", false, "Encrypt RTP and RTCP using SRTP with keys negotiated by DTLS, only when --transport is udp or tcp, requires --tls-ca or --tls-pin")
+	sendCmd.Flags().BoolVar(&reconnect, "reconnect", false, "Reconnect with backoff instead of exiting when the connection breaks")
+	sendCmd.Flags().StringSliceVar(&sendPaths, "path", nil, "Local interfaces or addresses to send on, one connection each, requires receiving with --multipath")
+	sendCmd.Flags().StringVar(&pathScheduler, "path-scheduler", "redundant", "Multipath scheduler: redundant, lowest-rtt or weighted")
+	sendCmd.Flags().UintSliceVar(&pathWeights, "path-weights", nil, "Weights of the paths for --path-scheduler weighted, in the order of --path")
+	sendCmd.Flags().StringVar(&pathDump, "path-dump", "", "Multipath statistics log file, use 'stdout' for Stdout")
+	sendCmd.Flags().StringSliceVar(&failover, "failover", nil, "Local interfaces in order of preference, the connection is moved when the active one goes down")
+	sendCmd.Flags().StringVar(&sendECN, "ecn", "not-ect", "ECN codepoint of outgoing packets: not-ect, ect0 or ect1, only when --transport is udp. NADA reacts to marks of ect1 packets like L4S")
+	sendCmd.Flags().BoolVar(&rtx, "rtx", false, "Retransmit packets the receiver NACKs, requires receiving with --nack, not with --transport tcp or QUIC streams")
+	sendCmd.Flags().DurationVar(&rtxDelay, "rtx-playout-delay", rtc.DefaultRTXPlayoutDelay, "How long after sending a packet the receiver plays it out, later retransmissions are skipped")
+	sendCmd.Flags().BoolVar(&senderFEC, "fec", false, "Protect the flows with FlexFEC packets, requires receiving with --fec, not with --transport tcp or QUIC streams")
+	sendCmd.Flags().Float64Var(&fecOverhead, "fec-overhead", 0, "FEC packets per media packet between 0.02 and 0.5, adapted to the loss rate if 0")
+	sendCmd.Flags().DurationVar(&streamReset, "stream-reset", 0, "Reset frame streams older than this duration, only with --roq-mapping stream-per-frame, 0 disables resetting")
 }
 
 var sendCmd = &cobra.Command{
@@ -94,17 +146,87 @@ func startSender() error {
 	defer rtpDumpFile.Close()
 	defer rtcpDumpFile.Close()
 
+	mapping, err := rtc.ParseRoQMapping(roqMapping)
+	if err != nil {
+		return err
+	}
+
 	c := rtc.SenderConfig{
-		RTPDump:        rtpDumpFile,
-		RTCPDump:       rtcpDumpFile,
-		CCDump:         ccDumpFile,
-		SCReAM:         scream,
-		GCC:            gcc,
-		LocalRFC8888:   localRFC8888,
-		InitialBitrate: initialBitrate,
+		RTPDump:               rtpDumpFile,
+		RTCPDump:              rtcpDumpFile,
+		CCDump:                ccDumpFile,
+		LocalRFC8888:          localRFC8888,
+		LocalTWCC:             localTWCC,
+		InitialBitrate:        initialBitrate,
+		RateControlInterval:   ccInterval,
+		RateControlOnFeedback: ccOnFeedback,
+		RTCPReports:           senderReports,
+		RTX:                   rtx,
+		RTXPlayoutDelay:       rtxDelay,
+		FEC:                   senderFEC,
+		FECOverhead:           fecOverhead,
+		Mapping:               mapping,
+		StreamResetAfter:      streamReset,
+	}
+	if localTWCC && (sendTransport != "quic" || mapping != rtc.DatagramMapping) {
+		return fmt.Errorf("--local-twcc requires --transport quic and --roq-mapping datagram")
+	}
+	if rtx && (sendTransport == "tcp" || mapping != rtc.DatagramMapping) {
+		return fmt.Errorf("--rtx requires --transport quic with --roq-mapping datagram or --transport udp")
+	}
+	if senderFEC && (sendTransport == "tcp" || mapping != rtc.DatagramMapping) {
+		return fmt.Errorf("--fec requires --transport quic with --roq-mapping datagram or --transport udp")
+	}
+	if fecOverhead < 0 || fecOverhead > 0.5 {
+		return fmt.Errorf("--fec-overhead must be between 0 and 0.5")
+	}
+	ecn, err := rtc.ParseECN(sendECN)
+	if err != nil {
+		return err
+	}
+	if ecn != rtc.NotECT && sendTransport != "udp" {
+		return fmt.Errorf("--ecn requires --transport udp")
+	}
+	controller, err := rateControllerName()
+	if err != nil {
+		return err
+	}
+	if controller == "quic-cwnd" && (sendTransport != "quic" || !newReno) {
+		return fmt.Errorf("rate controller quic-cwnd requires --transport quic and --newreno")
+	}
+	if controller != "" {
+		if rateSchedule != "" {
+			return fmt.Errorf("--rate-schedule conflicts with rate controller %v", controller)
+		}
+		c.RateController, err = rtc.NewRateController(controller, rtc.RateControllerConfig{
+			InitialBitrate: initialBitrate,
+			L4S:            ecn == rtc.ECT1,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if rateSchedule != "" {
+		c.RateController, err = loadRateSchedule(rateSchedule)
+		if err != nil {
+			return err
+		}
+	}
+	// The receiver's certificate must be issued for the host of --addr, which
+	// is also the name QUIC sends for SNI.
+	senderTLS.ServerName, _, err = net.SplitHostPort(sendAddr)
+	if err != nil {
+		return err
+	}
+	c.SRTP, err = srtpConfig(sendTransport, senderSRTPKey, senderDTLSSRTP, func() (*tls.Config, error) {
+		return rtc.NewClientTLSConfig(senderTLS)
+	})
+	if err != nil {
+		return err
 	}
 
-	var transport rtc.Transport
+	// dialFrom connects from the local address local, or any if nil.
+	var dialFrom func(local net.IP) (rtc.Transport, error)
 	switch sendTransport {
 	case "quic":
 		var qlogWriter logging.Tracer
@@ -112,60 +234,164 @@ func startSender() error {
 		if err != nil {
 			return err
 		}
-		var session quic.Session
-		var tracer *rtc.RTTTracer
-		session, tracer, err = connectQUIC(qlogWriter)
+		// Session tickets and address validation tokens are kept across
+		// reconnects.
+		var tlsConf *tls.Config
+		tlsConf, err = rtc.NewClientTLSConfig(senderTLS)
 		if err != nil {
 			return err
 		}
-		transport = &rtc.QUICTransport{
-			RTTTracer: tracer,
-			Session:   session,
-		}
-		if sendStream {
-			go streamSendLoop(session)
+		tokens := quic.NewLRUTokenStore(1, 4)
+		dialFrom = func(local net.IP) (rtc.Transport, error) {
+			session, tracer, err := connectQUIC(local, tlsConf, tokens, qlogWriter, mapping == rtc.DatagramMapping && !sendStream)
+			if err != nil {
+				return nil, err
+			}
+			if sendStream {
+				go streamSendLoop(session)
+			}
+			return &rtc.QUICTransport{
+				RTTTracer: tracer,
+				Session:   session,
+			}, nil
 		}
 
 	case "udp":
-		transport, err = connectUDP()
-		if err != nil {
-			return err
+		if mapping != rtc.DatagramMapping {
+			return fmt.Errorf("--roq-mapping %v requires --transport quic", mapping)
+		}
+		dialFrom = func(local net.IP) (rtc.Transport, error) {
+			client, err := rtc.DialUDPFrom(local, sendAddr)
+			if err != nil {
+				return nil, err
+			}
+			if ecn != rtc.NotECT {
+				if err := client.SetECN(ecn); err != nil {
+					client.CloseWithError(0, "eos")
+					return nil, err
+				}
+			}
+			return client, nil
 		}
 
 	case "tcp":
-		transport, err = connectTCP()
-		if err != nil {
-			return err
+		if mapping != rtc.DatagramMapping {
+			return fmt.Errorf("--roq-mapping %v requires --transport quic", mapping)
+		}
+		dialFrom = func(local net.IP) (rtc.Transport, error) {
+			client, err := connectTCP(local)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
 		}
 
 	default:
 		return fmt.Errorf("unknown transport protocol: %v", sendTransport)
 	}
+	dial := func() (rtc.Transport, error) {
+		return dialFrom(nil)
+	}
+	if len(sendPaths) > 0 && len(failover) > 0 {
+		return fmt.Errorf("--path and --failover are mutually exclusive")
+	}
+	if len(senderSRTPKey) > 0 && (len(sendPaths) > 0 || len(failover) > 0) {
+		return fmt.Errorf("--srtp-key can't be used with --path or --failover, the paths don't share a session nonce")
+	}
+	if len(failover) > 0 {
+		if mapping != rtc.DatagramMapping {
+			return fmt.Errorf("--failover requires --roq-mapping datagram")
+		}
+		dial = func() (rtc.Transport, error) {
+			t, err := rtc.NewFailoverTransport(failover, dialFrom)
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		}
+	}
+	if len(sendPaths) > 0 {
+		if mapping != rtc.DatagramMapping {
+			return fmt.Errorf("--path requires --roq-mapping datagram")
+		}
+		var scheduler rtc.PathScheduler
+		scheduler, err = rtc.ParsePathScheduler(pathScheduler)
+		if err != nil {
+			return err
+		}
+		var pathDumpFile io.WriteCloser
+		pathDumpFile, err = getLogFile(pathDump)
+		if err != nil {
+			return err
+		}
+		defer pathDumpFile.Close()
+		dialers := pathDialers(dialFrom)
+		dial = func() (rtc.Transport, error) {
+			t, err := rtc.NewMultipathTransport(dialers, rtc.MultipathConfig{
+				Scheduler: scheduler,
+				Dump:      pathDumpFile,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		}
+	}
+	transport, err := dial()
+	if err != nil {
+		return err
+	}
+	if reconnect {
+		c.Redial = dial
+	}
 	senderFactory, err := rtc.GstreamerSenderFactory(ctx, c, transport)
 	if err != nil {
 		return err
 	}
 
-	var src rtc.MediaSource
-	if senderCodec == "syncodec" {
-		src, err = syncodecPipeline(c.InitialBitrate)
+	if len(sources) > 1 && savePath != "" {
+		return fmt.Errorf("--save supports a single --source")
+	}
+	srcs := make([]rtc.MediaSource, len(sources))
+	flowConfigs := make([]rtc.FlowConfig, len(sources))
+	for i, entry := range sources {
+		var source string
+		source, flowConfigs[i], err = parseSource(entry)
 		if err != nil {
 			return err
 		}
-	} else {
+		if senderCodec == "syncodec" {
+			srcs[i], err = syncodecPipeline(c.InitialBitrate)
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		var gstSrc *gstsrc.Pipeline
-		gstSrc, err = gstSrcPipeline(senderCodec, source, 0, c.InitialBitrate)
+		// A random SSRC lets the receiver recognize the flow when
+		// reconnecting, see rtc.SinkPool.
+		var ssrc uint
+		ssrc, err = randomSSRC()
+		if err != nil {
+			return err
+		}
+		gstSrc, err = gstSrcPipeline(senderCodec, source, ssrc, c.InitialBitrate)
 		if err != nil {
 			return err
 		}
 		defer gstSrc.Close()
-		src = gstSrc
+		srcs[i] = gstSource{gstSrc}
 	}
 
-	s, err := senderFactory(src)
+	s, err := senderFactory()
 	if err != nil {
 		return err
 	}
+	for i, src := range srcs {
+		if err := s.AddFlowWithConfig(uint64(i), src, flowConfigs[i]); err != nil {
+			return err
+		}
+	}
 
 	defer s.Close()
 	errCh := make(chan error)
@@ -184,6 +410,33 @@ func startSender() error {
 	}
 }
 
+// srtpConfig returns the SRTP configuration selected by the --srtp-key and
+// --dtls-srtp flags, or nil if SRTP is disabled. With DTLS-SRTP, the TLS flags
+// select the certificates, see tlsConfig.
+func srtpConfig(transport, key string, dtls bool, tlsConfig func() (*tls.Config, error)) (*rtc.SRTPConfig, error) {
+	if len(key) == 0 && !dtls {
+		return nil, nil
+	}
+	if len(key) > 0 && dtls {
+		return nil, fmt.Errorf("--srtp-key and --dtls-srtp are mutually exclusive")
+	}
+	if transport != "udp" && transport != "tcp" {
+		return nil, fmt.Errorf("SRTP requires --transport udp or tcp, QUIC is encrypted already")
+	}
+	if dtls {
+		conf, err := tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		return &rtc.SRTPConfig{DTLS: conf}, nil
+	}
+	k, err := rtc.ParseSRTPKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &rtc.SRTPConfig{Key: k}, nil
+}
+
 func getQLOGTracer(path string) (logging.Tracer, error) {
 	if len(path) == 0 {
 		return nil, nil
@@ -215,11 +468,15 @@ func getQLOGTracer(path string) (logging.Tracer, error) {
 	}), nil
 }
 
-func connectQUIC(qlogger logging.Tracer) (quic.Session, *rtc.RTTTracer, error) {
-	tlsConf := &tls.Config{
-		InsecureSkipVerify: true,
-		NextProtos:         []string{"rtq"},
-	}
+// connectQUIC resumes the previous session if tlsConf has a session ticket for
+// the receiver. If earlyData is set, the session is returned before the
+// handshake completes to send media datagrams as 0-RTT data, as long as the
+// transport parameters remembered from the previous session allow datagrams.
+// The tracer reports the restored parameters, since ConnectionState blocks
+// until the handshake completes.
+// Streams must wait for the handshake, since they are reset if the receiver
+// rejects 0-RTT.
+func connectQUIC(local net.IP, tlsConf *tls.Config, tokens quic.TokenStore, qlogger logging.Tracer, earlyData bool) (quic.Session, *rtc.RTTTracer, error) {
 	metricsTracer := rtc.NewTracer()
 	tracers := []logging.Tracer{metricsTracer}
 	if qlogger != nil {
@@ -231,16 +488,46 @@ func connectQUIC(qlogger logging.Tracer) (quic.Session, *rtc.RTTTracer, error) {
 		HandshakeIdleTimeout: 15 * time.Second,
 		Tracer:               tracer,
 		DisableCC:            !newReno,
+		TokenStore:           tokens,
 	}
-	session, err := quic.DialAddr(sendAddr, tlsConf, quicConf)
+	raddr, err := net.ResolveUDPAddr("udp", sendAddr)
 	if err != nil {
 		return nil, nil, err
 	}
-	return session, metricsTracer, nil
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: local})
+	if err != nil {
+		return nil, nil, err
+	}
+	start := time.Now()
+	session, err := quic.DialEarly(conn, raddr, sendAddr, tlsConf, quicConf)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	go func() {
+		<-session.Context().Done()
+		conn.Close()
+	}()
+	if earlyData && metricsTracer.EarlyDatagrams() {
+		select {
+		case <-session.HandshakeComplete().Done():
+		default:
+			log.Printf("sending 0-RTT data after %v\n", time.Since(start))
+		}
+		go rtc.WaitHandshake(session, start)
+		return session, metricsTracer, nil
+	}
+	if err := rtc.WaitHandshake(session, start); err != nil {
+		return nil, nil, err
+	}
+	return session.NextSession(), metricsTracer, nil
 }
 
 func streamSendLoop(session quic.Session) error {
 	log.Println("Open stream")
+	// The stream carries zeros, which receivers read as empty RTP over QUIC
+	// packets of flow 0 and drop, so it can be a unidirectional stream like
+	// those of the stream mappings.
 	stream, err := session.OpenUniStream()
 	if err != nil {
 		return err
@@ -255,6 +542,110 @@ func streamSendLoop(session quic.Session) error {
 	}
 }
 
+// rateControllerName returns the rate controller selected by --cc or one of
+// its shortcuts --gcc, --scream and --nada.
+func rateControllerName() (string, error) {
+	name := ccName
+	for flag, shortcut := range map[string]bool{"gcc": gcc, "scream": scream, "nada": nada} {
+		if !shortcut {
+			continue
+		}
+		if name != "" && name != flag {
+			return "", fmt.Errorf("--%v conflicts with rate controller %v", flag, name)
+		}
+		name = flag
+	}
+	return name, nil
+}
+
+func loadRateSchedule(name string) (rtc.RateController, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	schedule, err := rtc.ParseRateSchedule(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rate schedule %v: %w", name, err)
+	}
+	return rtc.NewRateScheduleController(schedule), nil
+}
+
+// parseSource splits a --source entry into the source and the options of its
+// flow, which are appended as ',key=value'.
+func parseSource(entry string) (string, rtc.FlowConfig, error) {
+	var c rtc.FlowConfig
+	fields := strings.Split(entry, ",")
+	// Sources may contain commas themselves, so only trailing fields which
+	// are options are split off.
+	for len(fields) > 1 {
+		option := strings.SplitN(fields[len(fields)-1], "=", 2)
+		if len(option) != 2 {
+			break
+		}
+		key, value := option[0], option[1]
+		var err error
+		switch key {
+		case "weight":
+			var w uint64
+			w, err = strconv.ParseUint(value, 10, 32)
+			c.Weight = uint(w)
+		case "priority":
+			c.Priority, err = strconv.Atoi(value)
+		case "min":
+			var b uint64
+			b, err = strconv.ParseUint(value, 10, 32)
+			c.MinBitrate = uint(b)
+		case "max":
+			var b uint64
+			b, err = strconv.ParseUint(value, 10, 32)
+			c.MaxBitrate = uint(b)
+		default:
+			return strings.Join(fields, ","), c, nil
+		}
+		if err != nil {
+			return "", c, fmt.Errorf("invalid %v of source %v: %w", key, entry, err)
+		}
+		fields = fields[:len(fields)-1]
+	}
+	if c.MaxBitrate > 0 && c.MinBitrate > c.MaxBitrate {
+		return "", c, fmt.Errorf("min exceeds max of source %v", entry)
+	}
+	return strings.Join(fields, ","), c, nil
+}
+
+// pathDialers returns a dialer for every --path, which resolves the address of
+// the path on every dial.
+func pathDialers(dialFrom func(net.IP) (rtc.Transport, error)) []rtc.PathDialer {
+	dialers := make([]rtc.PathDialer, 0, len(sendPaths))
+	for i, name := range sendPaths {
+		name := name
+		d := rtc.PathDialer{
+			Name: name,
+			Dial: func() (rtc.Transport, error) {
+				local, err := rtc.LocalIP(name)
+				if err != nil {
+					return nil, err
+				}
+				return dialFrom(local)
+			},
+		}
+		if i < len(pathWeights) {
+			d.Weight = pathWeights[i]
+		}
+		dialers = append(dialers, d)
+	}
+	return dialers
+}
+
+func randomSSRC() (uint, error) {
+	var buf [4]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, err
+	}
+	return uint(binary.BigEndian.Uint32(buf[:])), nil
+}
+
 func gstSrcPipeline(codec string, src string, ssrc uint, initialBitrate uint) (*gstsrc.Pipeline, error) {
 	if src == "highrate" {
 		src = "videotestsrc ! video/x-raw,framerate=30/1,width=1920,height=1080 ! clocksync"
@@ -276,18 +667,41 @@ func gstSrcPipeline(codec string, src string, ssrc uint, initialBitrate uint) (*
 	return srcPipeline, nil
 }
 
+// gstSource is a GStreamer pipeline as rtc.MediaSource. Keyframes are forced
+// with a GstForceKeyUnit event to the encoder of the pipeline.
+type gstSource struct {
+	*gstsrc.Pipeline
+}
+
+// syntheticKeyframeScale is how much larger a forced keyframe of the
+// synthetic encoder is than the frame it replaces.
+const syntheticKeyframeScale = 5
+
 type syntheticEncoder struct {
 	io.Reader
 	syncodec.Codec
 	writer io.Writer
+	// keyframe is set to 1 if the next frame is a forced keyframe.
+	keyframe int32
 }
 
 func (e *syntheticEncoder) SetBitRate(target uint) {
 	e.SetTargetBitrate(int(target))
 }
 
+// ForceKeyframe makes the next frame a keyframe. Synthetic frames have no
+// frame types, so keyframes are modeled by their size.
+func (e *syntheticEncoder) ForceKeyframe() error {
+	atomic.StoreInt32(&e.keyframe, 1)
+	return nil
+}
+
 func (e *syntheticEncoder) WriteFrame(frame syncodec.Frame) {
-	e.writer.Write(frame.Content)
+	content := frame.Content
+	if atomic.CompareAndSwapInt32(&e.keyframe, 1, 0) {
+		content = make([]byte, len(content)*syntheticKeyframeScale)
+	}
+	e.writer.Write(content)
 }
 
 func (e *syntheticEncoder) Close() error {
@@ -310,44 +724,7 @@ func syncodecPipeline(initialBitrate uint) (rtc.MediaSource, error) {
 	return sw, nil
 }
 
-func connectUDP() (*udpClient, error) {
-	a, err := net.ResolveUDPAddr("udp", sendAddr)
-	if err != nil {
-		return nil, err
-	}
-	conn, err := net.DialUDP("udp", nil, a)
-	if err != nil {
-		return nil, err
-	}
-	return &udpClient{
-		conn: conn,
-	}, nil
-}
-
-type udpClient struct {
-	conn *net.UDPConn
-}
-
-func (c *udpClient) SendMessage(msg []byte, _ func(error), _ func(bool)) error {
-	_, err := c.conn.Write(msg)
-	return err
-}
-
-func (c *udpClient) ReceiveMessage() ([]byte, error) {
-	buf := make([]byte, 1400)
-	n, err := c.conn.Read(buf)
-	return buf[:n], err
-}
-
-func (c *udpClient) CloseWithError(int, string) error {
-	return c.conn.Close()
-}
-
-func (t *udpClient) Metrics() rtc.RTTStats {
-	panic(fmt.Errorf("UDP does not provide metrics"))
-}
-
-func connectTCP() (*tcpClient, error) {
+func connectTCP(local net.IP) (*tcpClient, error) {
 	dialer := &net.Dialer{
 		Control: func(_, _ string, c syscall.RawConn) error {
 			var operr error
@@ -362,40 +739,35 @@ func connectTCP() (*tcpClient, error) {
 			return nil
 		},
 	}
+	if local != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: local}
+	}
 	conn, err := dialer.Dial("tcp", sendAddr)
 	if err != nil {
 		return nil, err
 	}
-	// TODO: Use something like this for tcp cwnd logging:
-	//go func() {
-	//	t := time.NewTicker(200 * time.Millisecond)
-	//	for range t.C {
-	//		conn, ok := conn.(interface {
-	//			SyscallConn() (syscall.RawConn, error)
-	//		})
-	//		if !ok {
-	//			panic(errors.New("doesn't have a SyscallConn"))
-	//		}
-	//		rawConn, err := conn.SyscallConn()
-	//		if err != nil {
-	//			panic(fmt.Errorf("couldn't get syscall.RawConn: %w", err))
-	//		}
-	//		rawConn.Control(func(fd uintptr) {
-	//			info, serr := unix.GetsockoptTCPInfo(int(fd), unix.SOL_TCP, unix.TCP_INFO)
-	//			if serr != nil {
-	//				panic(serr)
-	//			}
-	//			fmt.Printf("%v\n", info.Snd_cwnd)
-	//		})
-	//	}
-	//}()
+	nonce, err := rtc.ExchangeTCPNonces(conn, true)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
 	return &tcpClient{
-		conn: conn,
+		TCPMetricer: rtc.NewTCPMetricer(conn),
+		conn:        conn,
+		nonce:       nonce,
 	}, nil
 }
 
 type tcpClient struct {
-	conn net.Conn
+	*rtc.TCPMetricer
+	conn  net.Conn
+	nonce []byte
+}
+
+// SessionNonce returns the nonces exchanged when connecting, see
+// rtc.ExchangeTCPNonces.
+func (c *tcpClient) SessionNonce() []byte {
+	return c.nonce
 }
 
 func (c *tcpClient) SendMessage(msg []byte, _ func(error), _ func(bool)) error {
@@ -428,7 +800,3 @@ func (c *tcpClient) ReceiveMessage() ([]byte, error) {
 func (c *tcpClient) CloseWithError(int, string) error {
 	return c.conn.Close()
 }
-
-func (t *tcpClient) Metrics() rtc.RTTStats {
-	panic(fmt.Errorf("TCP does not provide metrics"))
-}
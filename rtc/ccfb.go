@@ -0,0 +1,465 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+)
+
+// CCFBFormat selects the wire format of RFC 8888 congestion control feedback.
+type CCFBFormat uint8
+
+const (
+	// CCFBDraft is the format of draft-ietf-avtcore-cc-feedback-message-02
+	// implemented by SCReAM: FMT 0 and the number of reports minus one.
+	CCFBDraft CCFBFormat = ccfbDraftFormat
+	// CCFBRFC8888 is the format of RFC 8888: FMT 11 and the number of
+	// reports.
+	CCFBRFC8888 CCFBFormat = ccfbFormat
+)
+
+func ParseCCFBFormat(s string) (CCFBFormat, error) {
+	switch s {
+	case "draft":
+		return CCFBDraft, nil
+	case "rfc8888":
+		return CCFBRFC8888, nil
+	}
+	return 0, fmt.Errorf("unknown RFC 8888 format: %v", s)
+}
+
+func (f CCFBFormat) String() string {
+	switch f {
+	case CCFBDraft:
+		return "draft"
+	case CCFBRFC8888:
+		return "rfc8888"
+	}
+	return fmt.Sprintf("CCFBFormat(%d)", int(f))
+}
+
+const (
+	ccfbFormat = 11
+	// SCReAM implements draft-ietf-avtcore-cc-feedback-message-02, which
+	// uses format 0 and sends the number of reports minus one.
+	ccfbDraftFormat = 0
+
+	// CCFBArrivalUnavailable is the arrival time offset of received packets
+	// without arrival time.
+	CCFBArrivalUnavailable = 0x1fff
+	// ccfbMaxArrivalOffset is reported for packets which arrived longer
+	// before the report than the field can express.
+	ccfbMaxArrivalOffset = 0x1ffe
+
+	// DefaultCCFBInterval is how often RFC 8888 feedback is sent if
+	// ReceiverConfig.RFC8888Interval is zero.
+	DefaultCCFBInterval = 10 * time.Millisecond
+
+	// ccfbMaxReports limits the reports of a stream in a single feedback
+	// packet. Older packets are neither reported as received nor as lost.
+	ccfbMaxReports = 256
+	// ccfbMaxSize keeps feedback packets within a QUIC datagram.
+	ccfbMaxSize = 1024
+)
+
+var (
+	errNotCCFB     = errors.New("not an RFC 8888 feedback packet")
+	errInvalidCCFB = errors.New("invalid RFC 8888 feedback packet")
+)
+
+// ecnAttribute is the key of the ECN codepoint in the attributes of received
+// RTP packets, set by transports which can read it.
+type ecnAttribute struct{}
+
+// CCFeedback is an RFC 8888 congestion control feedback packet. It implements
+// rtcp.Packet.
+type CCFeedback struct {
+	Format       CCFBFormat
+	SenderSSRC   uint32
+	ReportBlocks []CCFeedbackReportBlock
+	// ReportTimestamp holds the middle 32 bits of the NTP time at which the
+	// report was sent.
+	ReportTimestamp uint32
+}
+
+// CCFeedbackReportBlock holds the reports of consecutive packets of a single
+// stream.
+type CCFeedbackReportBlock struct {
+	MediaSSRC     uint32
+	BeginSequence uint16
+	Reports       []CCFeedbackReport
+}
+
+type CCFeedbackReport struct {
+	Received bool
+	ECN      uint8
+	// ArrivalTimeOffset is the time from the arrival of the packet to the
+	// report timestamp in 1/1024 seconds, or CCFBArrivalUnavailable.
+	ArrivalTimeOffset uint16
+}
+
+func (p *CCFeedback) marshalSize() int {
+	size := 12
+	for _, b := range p.ReportBlocks {
+		size += blockSize(len(b.Reports))
+	}
+	return size
+}
+
+func blockSize(reports int) int {
+	return 8 + 2*(reports+reports%2)
+}
+
+func (p *CCFeedback) Marshal() ([]byte, error) {
+	size := p.marshalSize()
+	if size/4-1 > 0xffff {
+		return nil, errInvalidCCFB
+	}
+	buf := make([]byte, size)
+	buf[0] = 0x80 | uint8(p.Format)
+	buf[1] = uint8(rtcp.TypeTransportSpecificFeedback)
+	binary.BigEndian.PutUint16(buf[2:], uint16(size/4-1))
+	binary.BigEndian.PutUint32(buf[4:], p.SenderSSRC)
+	offset := 8
+	for _, b := range p.ReportBlocks {
+		n := len(b.Reports)
+		if n == 0 || n > 1<<14 {
+			return nil, errInvalidCCFB
+		}
+		binary.BigEndian.PutUint32(buf[offset:], b.MediaSSRC)
+		binary.BigEndian.PutUint16(buf[offset+4:], b.BeginSequence)
+		if p.Format == CCFBDraft {
+			n--
+		}
+		binary.BigEndian.PutUint16(buf[offset+6:], uint16(n))
+		for i, r := range b.Reports {
+			var m uint16
+			if r.Received {
+				m = 0x8000 | uint16(r.ECN&0x03)<<13 | r.ArrivalTimeOffset&0x1fff
+			}
+			binary.BigEndian.PutUint16(buf[offset+8+2*i:], m)
+		}
+		offset += blockSize(len(b.Reports))
+	}
+	binary.BigEndian.PutUint32(buf[offset:], p.ReportTimestamp)
+	return buf, nil
+}
+
+func (p *CCFeedback) Unmarshal(b []byte) error {
+	if len(b) < 4 {
+		return errNotCCFB
+	}
+	format := CCFBFormat(b[0] & 0x1f)
+	if rtcp.PacketType(b[1]) != rtcp.TypeTransportSpecificFeedback || (format != CCFBRFC8888 && format != CCFBDraft) {
+		return errNotCCFB
+	}
+	length := (int(binary.BigEndian.Uint16(b[2:])) + 1) * 4
+	if length > len(b) || length < 12 {
+		return errInvalidCCFB
+	}
+	b = b[:length]
+	*p = CCFeedback{
+		Format:          format,
+		SenderSSRC:      binary.BigEndian.Uint32(b[4:]),
+		ReportTimestamp: binary.BigEndian.Uint32(b[length-4:]),
+	}
+	body := b[8 : length-4]
+	for len(body) > 0 {
+		if len(body) < 8 {
+			return errInvalidCCFB
+		}
+		block := CCFeedbackReportBlock{
+			MediaSSRC:     binary.BigEndian.Uint32(body),
+			BeginSequence: binary.BigEndian.Uint16(body[4:]),
+		}
+		n := int(binary.BigEndian.Uint16(body[6:]))
+		if format == CCFBDraft {
+			n++
+		}
+		size := blockSize(n)
+		if len(body) < size {
+			return errInvalidCCFB
+		}
+		block.Reports = make([]CCFeedbackReport, n)
+		for i := range block.Reports {
+			m := binary.BigEndian.Uint16(body[8+2*i:])
+			if m&0x8000 != 0 {
+				block.Reports[i] = CCFeedbackReport{
+					Received:          true,
+					ECN:               uint8(m>>13) & 0x03,
+					ArrivalTimeOffset: m & 0x1fff,
+				}
+			}
+		}
+		p.ReportBlocks = append(p.ReportBlocks, block)
+		body = body[size:]
+	}
+	return nil
+}
+
+func (p *CCFeedback) DestinationSSRC() []uint32 {
+	ssrcs := make([]uint32, 0, len(p.ReportBlocks))
+	for _, b := range p.ReportBlocks {
+		ssrcs = append(ssrcs, b.MediaSSRC)
+	}
+	return ssrcs
+}
+
+// String lists the reports of every block, starting at the begin sequence
+// number. Received packets are shown with their arrival time offset in ms,
+// '?' if unavailable, and the ECN codepoint if set. Lost packets are shown as
+// '-'.
+func (p *CCFeedback) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ccfb format=%v sender=%v ts=%v", p.Format, p.SenderSSRC, p.ReportTimestamp)
+	for _, block := range p.ReportBlocks {
+		fmt.Fprintf(&b, " ssrc=%v begin=%v [", block.MediaSSRC, block.BeginSequence)
+		for i, r := range block.Reports {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			if !r.Received {
+				b.WriteByte('-')
+				continue
+			}
+			if r.ArrivalTimeOffset == CCFBArrivalUnavailable {
+				b.WriteByte('?')
+			} else {
+				fmt.Fprintf(&b, "%.1f", float64(r.ArrivalTimeOffset)*1000/1024)
+			}
+			if r.ECN != 0 {
+				fmt.Fprintf(&b, "/ecn%v", r.ECN)
+			}
+		}
+		b.WriteByte(']')
+	}
+	return b.String()
+}
+
+type ccfbArrival struct {
+	at  time.Time
+	ecn uint8
+}
+
+type ccfbStream struct {
+	seq unwrapper
+	// next is the first unwrapped sequence number which was not reported
+	// yet.
+	next     int64
+	highest  int64
+	arrivals map[int64]ccfbArrival
+}
+
+// ccfbGenerator builds RFC 8888 feedback from the arrivals of RTP packets.
+// Every packet is reported once, packets which arrive after a later packet
+// was reported are ignored.
+type ccfbGenerator struct {
+	format  CCFBFormat
+	streams map[uint32]*ccfbStream
+}
+
+func newCCFBGenerator(format CCFBFormat) *ccfbGenerator {
+	return &ccfbGenerator{
+		format:  format,
+		streams: map[uint32]*ccfbStream{},
+	}
+}
+
+func (g *ccfbGenerator) add(ssrc uint32, seq uint16, at time.Time, ecn uint8) {
+	s, ok := g.streams[ssrc]
+	if !ok {
+		s = &ccfbStream{
+			arrivals: map[int64]ccfbArrival{},
+		}
+		g.streams[ssrc] = s
+	}
+	n := s.seq.unwrap(seq)
+	if !ok {
+		s.next, s.highest = n, n-1
+	}
+	if n < s.next {
+		return
+	}
+	s.arrivals[n] = ccfbArrival{at: at, ecn: ecn}
+	if n > s.highest {
+		s.highest = n
+	}
+}
+
+func (g *ccfbGenerator) remove(ssrc uint32) {
+	delete(g.streams, ssrc)
+}
+
+// feedback reports all packets received since the last call, split into as
+// many feedback packets as needed to stay below ccfbMaxSize. It returns nil
+// if there is nothing to report.
+func (g *ccfbGenerator) feedback(now time.Time) []*CCFeedback {
+	ssrcs := make([]uint32, 0, len(g.streams))
+	for ssrc := range g.streams {
+		ssrcs = append(ssrcs, ssrc)
+	}
+	sort.Slice(ssrcs, func(i, j int) bool { return ssrcs[i] < ssrcs[j] })
+
+	ts := ntpCompact(now)
+	var pkts []*CCFeedback
+	var pkt *CCFeedback
+	for _, ssrc := range ssrcs {
+		s := g.streams[ssrc]
+		if s.highest < s.next {
+			continue
+		}
+		begin := s.next
+		if s.highest-begin >= ccfbMaxReports {
+			begin = s.highest - ccfbMaxReports + 1
+		}
+		block := CCFeedbackReportBlock{
+			MediaSSRC:     ssrc,
+			BeginSequence: uint16(begin),
+			Reports:       make([]CCFeedbackReport, 0, s.highest-begin+1),
+		}
+		for n := begin; n <= s.highest; n++ {
+			a, ok := s.arrivals[n]
+			if !ok {
+				block.Reports = append(block.Reports, CCFeedbackReport{})
+				continue
+			}
+			offset := now.Sub(a.at) * 1024 / time.Second
+			if offset < 0 {
+				offset = 0
+			}
+			if offset > ccfbMaxArrivalOffset {
+				offset = ccfbMaxArrivalOffset
+			}
+			block.Reports = append(block.Reports, CCFeedbackReport{
+				Received:          true,
+				ECN:               a.ecn,
+				ArrivalTimeOffset: uint16(offset),
+			})
+		}
+		for n := s.next; n <= s.highest; n++ {
+			delete(s.arrivals, n)
+		}
+		s.next = s.highest + 1
+
+		if pkt == nil || pkt.marshalSize()+blockSize(len(block.Reports)) > ccfbMaxSize {
+			pkt = &CCFeedback{
+				Format:          g.format,
+				ReportTimestamp: ts,
+			}
+			pkts = append(pkts, pkt)
+		}
+		pkt.ReportBlocks = append(pkt.ReportBlocks, block)
+	}
+	return pkts
+}
+
+type ccfbReceiverFactory struct {
+	format   CCFBFormat
+	interval time.Duration
+	dump     io.Writer
+}
+
+func (f *ccfbReceiverFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return &ccfbReceiver{
+		generator: newCCFBGenerator(f.format),
+		interval:  f.interval,
+		dump:      f.dump,
+		close:     make(chan struct{}),
+	}, nil
+}
+
+// ccfbReceiver sends RFC 8888 feedback for all received RTP packets every
+// interval.
+type ccfbReceiver struct {
+	interceptor.NoOp
+	lock      sync.Mutex
+	generator *ccfbGenerator
+	interval  time.Duration
+	dump      io.Writer
+
+	wg        sync.WaitGroup
+	close     chan struct{}
+	closeOnce sync.Once
+}
+
+func (r *ccfbReceiver) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	r.wg.Add(1)
+	go r.loop(writer)
+	return writer
+}
+
+func (r *ccfbReceiver) BindRemoteStream(_ *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+		now := time.Now()
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		header, err := attr.GetRTPHeader(b[:n])
+		if err != nil {
+			return 0, nil, err
+		}
+		// Retransmitted and recovered packets are late by design,
+		// reporting them would look like queuing delay.
+		rtx, _ := attr.Get(rtxAttribute{}).(bool)
+		recovered, _ := attr.Get(fecAttribute{}).(bool)
+		if rtx || recovered {
+			return n, attr, nil
+		}
+		ecn, _ := attr.Get(ecnAttribute{}).(uint8)
+		r.lock.Lock()
+		r.generator.add(header.SSRC, header.SequenceNumber, now, ecn)
+		r.lock.Unlock()
+		return n, attr, nil
+	})
+}
+
+func (r *ccfbReceiver) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.generator.remove(info.SSRC)
+}
+
+func (r *ccfbReceiver) loop(writer interceptor.RTCPWriter) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.close:
+			return
+		case now := <-ticker.C:
+			r.lock.Lock()
+			pkts := r.generator.feedback(now)
+			r.lock.Unlock()
+			for _, pkt := range pkts {
+				if r.dump != nil {
+					fmt.Fprintf(r.dump, "%v\t%v\n", now.Format(time.RFC3339Nano), pkt)
+				}
+				if _, err := writer.Write([]rtcp.Packet{pkt}, nil); err != nil {
+					log.Printf("failed to send RFC 8888 feedback: %v\n", err)
+				}
+			}
+		}
+	}
+}
+
+func (r *ccfbReceiver) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.close)
+	})
+	r.wg.Wait()
+	return nil
+}
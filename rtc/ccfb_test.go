@@ -0,0 +1,86 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCCFeedbackMarshal(t *testing.T) {
+	fb := &CCFeedback{
+		Format:     CCFBDraft,
+		SenderSSRC: 1,
+		ReportBlocks: []CCFeedbackReportBlock{{
+			MediaSSRC:     2,
+			BeginSequence: 10,
+			Reports: []CCFeedbackReport{
+				{Received: true, ArrivalTimeOffset: 1024},
+				{},
+				{Received: true, ECN: ecnCE, ArrivalTimeOffset: CCFBArrivalUnavailable},
+			},
+		}},
+		ReportTimestamp: 2 << 16,
+	}
+	buf, err := fb.Marshal()
+	assert.NoError(t, err)
+	// The same packet as in TestCCFBReports.
+	assert.Equal(t, []byte{
+		0x80, 205, 0x00, 0x06,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x0a, 0x00, 0x02,
+		0x84, 0x00,
+		0x00, 0x00,
+		0xff, 0xff,
+		0x00, 0x00,
+		0x00, 0x02, 0x00, 0x00,
+	}, buf)
+	assert.Equal(t, "ccfb format=draft sender=1 ts=131072 ssrc=2 begin=10 [1000.0 - ?/ecn3]", fb.String())
+
+	fb.Format = CCFBRFC8888
+	buf, err = fb.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x8b, 205}, buf[:2])
+	assert.Equal(t, []byte{0x00, 0x03}, buf[14:16])
+	var parsed CCFeedback
+	assert.NoError(t, parsed.Unmarshal(buf))
+	assert.Equal(t, *fb, parsed)
+}
+
+func TestCCFBGenerator(t *testing.T) {
+	g := newCCFBGenerator(CCFBRFC8888)
+	now := time.Now()
+	assert.Nil(t, g.feedback(now))
+
+	g.add(7, 65535, now.Add(-time.Second), 0)
+	g.add(7, 1, now.Add(-500*time.Millisecond), 1)
+	fb := g.feedback(now)
+	assert.Len(t, fb, 1)
+	assert.Equal(t, ntpCompact(now), fb[0].ReportTimestamp)
+	assert.Equal(t, []CCFeedbackReportBlock{{
+		MediaSSRC:     7,
+		BeginSequence: 65535,
+		Reports: []CCFeedbackReport{
+			{Received: true, ArrivalTimeOffset: 1024},
+			{},
+			{Received: true, ECN: 1, ArrivalTimeOffset: 512},
+		},
+	}}, fb[0].ReportBlocks)
+
+	// Reported packets are not reported again, late packets are ignored.
+	g.add(7, 0, now, 0)
+	assert.Nil(t, g.feedback(now))
+
+	// Many streams are split across packets.
+	for ssrc := uint32(0); ssrc < 5; ssrc++ {
+		for seq := uint16(0); seq < 100; seq++ {
+			g.add(ssrc, seq, now, 0)
+		}
+	}
+	fb = g.feedback(now)
+	assert.Len(t, fb, 2)
+	for _, p := range fb {
+		assert.LessOrEqual(t, p.marshalSize(), ccfbMaxSize)
+	}
+}
@@ -0,0 +1,81 @@
+package rtc
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/pion/interceptor"
+)
+
+const (
+	// cwndProbeGain raises the target over the rate of the congestion window
+	// while the connection is application limited, since NewReno only grows
+	// the window while it is fully used.
+	cwndProbeGain = 1.25
+	// cwndLimitedUtilization is the fraction of the congestion window in
+	// flight above which the connection counts as window limited.
+	cwndLimitedUtilization = 0.5
+)
+
+var errNoCwnd = errors.New("transport reports no congestion window")
+
+type cwndController struct {
+	config RateControllerConfig
+}
+
+func (c *cwndController) Register(_ *interceptor.Registry, m Metricer, onEstimator func(BandwidthEstimator)) error {
+	onEstimator(&cwndEstimator{
+		config: c.config,
+		m:      m,
+		target: float64(c.config.InitialBitrate),
+	})
+	return nil
+}
+
+// cwndEstimator derives the target bitrate from the congestion controller of
+// the transport: one congestion window per smoothed RTT.
+type cwndEstimator struct {
+	config RateControllerConfig
+	m      Metricer
+
+	lock        sync.Mutex
+	utilization float64
+	target      float64
+	stats       RTTStats
+}
+
+func (e *cwndEstimator) GetTargetBitrate() (int, error) {
+	stats := e.m.Metrics()
+	if stats.Cwnd == 0 {
+		return 0, errNoCwnd
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.stats = stats
+	if stats.SmoothedRTT == 0 {
+		return int(e.target), nil
+	}
+	// In flight data is sampled at arbitrary times between bursts of
+	// frames, the average tells how much of the window is used.
+	e.utilization = 0.75*e.utilization + 0.25*float64(stats.BytesInFlight)/float64(stats.Cwnd)
+	rate := float64(stats.Cwnd*8) / stats.SmoothedRTT.Seconds()
+	if e.utilization < cwndLimitedUtilization {
+		rate *= cwndProbeGain
+	}
+	e.target = math.Max(float64(e.config.MinBitrate), math.Min(float64(e.config.MaxBitrate), rate))
+	return int(e.target), nil
+}
+
+func (e *cwndEstimator) GetStats() map[string]interface{} {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return map[string]interface{}{
+		"cwnd":          e.stats.Cwnd,
+		"bytesInFlight": e.stats.BytesInFlight,
+		"utilization":   e.utilization,
+		"srtt":          float64(e.stats.SmoothedRTT.Microseconds()) / 1000.0,
+		"lost":          e.stats.Lost,
+	}
+}
@@ -0,0 +1,43 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticMetricer RTTStats
+
+func (m *staticMetricer) Metrics() RTTStats {
+	return RTTStats(*m)
+}
+
+func TestCwndEstimator(t *testing.T) {
+	m := &staticMetricer{}
+	e := &cwndEstimator{
+		config: RateControllerConfig{MinBitrate: 100_000, MaxBitrate: 10_000_000},
+		m:      m,
+		target: 1_000_000,
+	}
+	_, err := e.GetTargetBitrate()
+	assert.ErrorIs(t, err, errNoCwnd)
+
+	// 50 KB per 100ms is 4 Mbps, probed while the window is barely used.
+	*m = staticMetricer{Cwnd: 50_000, SmoothedRTT: 100 * time.Millisecond}
+	target, err := e.GetTargetBitrate()
+	assert.NoError(t, err)
+	assert.Equal(t, 5_000_000, target)
+
+	m.BytesInFlight = 50_000
+	for i := 0; i < 10; i++ {
+		target, err = e.GetTargetBitrate()
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, 4_000_000, target)
+
+	m.Cwnd = 500_000
+	target, err = e.GetTargetBitrate()
+	assert.NoError(t, err)
+	assert.Equal(t, 10_000_000, target)
+}
@@ -0,0 +1,129 @@
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ECN is the ECN field of the IP header, see RFC 3168. ECT1 identifies L4S
+// traffic, see RFC 9331.
+type ECN uint8
+
+const (
+	NotECT ECN = 0b00
+	ECT1   ECN = 0b01
+	ECT0   ECN = 0b10
+	CE     ECN = 0b11
+)
+
+func ParseECN(s string) (ECN, error) {
+	switch strings.ToLower(s) {
+	case "", "not-ect":
+		return NotECT, nil
+	case "ect0":
+		return ECT0, nil
+	case "ect1":
+		return ECT1, nil
+	}
+	return 0, fmt.Errorf("unknown ECN codepoint: %v", s)
+}
+
+func (e ECN) String() string {
+	switch e {
+	case NotECT:
+		return "not-ect"
+	case ECT1:
+		return "ect1"
+	case ECT0:
+		return "ect0"
+	case CE:
+		return "ce"
+	}
+	return fmt.Sprintf("ECN(%d)", int(e))
+}
+
+// ecnCE is the ECN codepoint of congestion experienced packets.
+const ecnCE = uint8(CE)
+
+func rawConn(conn net.Conn) (syscall.RawConn, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil, errors.New("connection does not provide a syscall.RawConn")
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get syscall.RawConn: %w", err)
+	}
+	return rc, nil
+}
+
+func isIPv6(conn net.Conn) bool {
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	return ok && addr.IP.To4() == nil
+}
+
+// setECN marks all packets sent on conn with ecn. The other bits of the
+// traffic class are cleared.
+func setECN(conn *net.UDPConn, ecn ECN) error {
+	rc, err := rawConn(conn)
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		if isIPv6(conn) {
+			serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, int(ecn))
+			return
+		}
+		serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, int(ecn))
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// enableECNReceive requests the traffic class of received packets as
+// control messages, to be read by readECN.
+func enableECNReceive(conn *net.UDPConn) error {
+	rc, err := rawConn(conn)
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		// IPv4 options also apply to IPv4 packets on dual stack sockets.
+		serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_RECVTOS, 1)
+		if serr == nil && isIPv6(conn) {
+			serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_RECVTCLASS, 1)
+		}
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// readECN returns the ECN codepoint from the control messages oob of a
+// received packet, or NotECT if there is none.
+func readECN(oob []byte) ECN {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return NotECT
+	}
+	for _, m := range msgs {
+		switch {
+		case m.Header.Level == unix.IPPROTO_IP && m.Header.Type == unix.IP_TOS && len(m.Data) >= 1:
+			return ECN(m.Data[0] & 0b11)
+		case m.Header.Level == unix.IPPROTO_IPV6 && m.Header.Type == unix.IPV6_TCLASS && len(m.Data) >= 4:
+			// The traffic class is an int in host byte order. It fits
+			// into one byte, so either the first or the last byte
+			// holds it.
+			return ECN((m.Data[0] | m.Data[3]) & 0b11)
+		}
+	}
+	return NotECT
+}
@@ -0,0 +1,315 @@
+package rtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pion/rtcp"
+	"golang.org/x/sys/unix"
+)
+
+// failoverCheckInterval is how often the interfaces are checked in addition to
+// netlink notifications.
+const failoverCheckInterval = 5 * time.Second
+
+// Rebinder is implemented by transports which can move to another local
+// address without interrupting their session.
+type Rebinder interface {
+	Rebind(local net.IP) error
+}
+
+// FailoverTransport keeps its session on the first available of a list of
+// local interfaces, in the order of preference. It watches the links and
+// addresses of the interfaces via netlink and moves the session whenever the
+// first available interface changes. Transports implementing Rebinder are
+// moved in place, others are reconnected from the new interface. QUIC
+// connections are reconnected, since quic-go doesn't support migrating them
+// yet.
+type FailoverTransport struct {
+	interfaces []string
+	dialFrom   func(local net.IP) (Transport, error)
+	in         chan []byte
+	errCh      chan error
+	check      chan struct{}
+
+	lock      sync.Mutex
+	transport Transport
+	iface     string
+	local     net.IP
+	// stranded is set while no interface is available.
+	stranded bool
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+var errNoInterface = errors.New("no interface available")
+
+// errMigrating is returned when sending on the current transport of a
+// FailoverTransport failed. The message is dropped, but the session goes on
+// while it is moved to another interface.
+var errMigrating = errors.New("session migrating")
+
+// NewFailoverTransport connects from the first available of interfaces using
+// dialFrom.
+func NewFailoverTransport(interfaces []string, dialFrom func(local net.IP) (Transport, error)) (*FailoverTransport, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &FailoverTransport{
+		interfaces: interfaces,
+		dialFrom:   dialFrom,
+		in:         make(chan []byte, 1000),
+		errCh:      make(chan error, 1),
+		check:      make(chan struct{}, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	iface, local, ok := t.available()
+	if !ok {
+		cancel()
+		return nil, errNoInterface
+	}
+	transport, err := dialFrom(local)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	log.Printf("connected from %v (%v)\n", iface, local)
+	t.transport, t.iface, t.local = transport, iface, local
+
+	t.wg.Add(2)
+	go t.read(transport)
+	go t.watch()
+	return t, nil
+}
+
+// available returns the first interface which is up and has an address.
+func (t *FailoverTransport) available() (string, net.IP, bool) {
+	for _, name := range t.interfaces {
+		iface, err := net.InterfaceByName(name)
+		if err != nil || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		local, err := LocalIP(name)
+		if err != nil {
+			continue
+		}
+		return name, local, true
+	}
+	return "", nil, false
+}
+
+func (t *FailoverTransport) current() Transport {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.transport
+}
+
+func (t *FailoverTransport) watch() {
+	defer t.wg.Done()
+	events := make(chan struct{}, 1)
+	go func() {
+		if err := watchLinks(t.ctx, events); err != nil {
+			log.Printf("failed to watch links: %v, checking interfaces every %v\n", err, failoverCheckInterval)
+		}
+	}()
+	ticker := time.NewTicker(failoverCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-events:
+		case <-t.check:
+		case <-ticker.C:
+		}
+		t.update()
+	}
+}
+
+// update moves the session to the first available interface if it isn't
+// there already.
+func (t *FailoverTransport) update() {
+	iface, local, ok := t.available()
+	t.lock.Lock()
+	transport, from, fromLocal := t.transport, t.iface, t.local
+	stranded := t.stranded
+	t.stranded = !ok
+	t.lock.Unlock()
+	if !ok {
+		if !stranded {
+			log.Printf("%v no interface available, staying on %v (%v)\n", time.Now().Format(time.RFC3339Nano), from, fromLocal)
+		}
+		return
+	}
+	if iface == from && local.Equal(fromLocal) {
+		return
+	}
+
+	start := time.Now()
+	if r, ok := transport.(Rebinder); ok {
+		err := r.Rebind(local)
+		if err == nil {
+			t.lock.Lock()
+			t.iface, t.local = iface, local
+			t.lock.Unlock()
+			t.logFailover(start, from, fromLocal, iface, local, "rebound")
+			return
+		}
+		log.Printf("failed to rebind to %v (%v): %v, reconnecting\n", iface, local, err)
+	}
+
+	next, err := t.dialFrom(local)
+	if err != nil {
+		log.Printf("%v failed to connect from %v (%v): %v\n", time.Now().Format(time.RFC3339Nano), iface, local, err)
+		return
+	}
+	t.lock.Lock()
+	if t.ctx.Err() != nil {
+		t.lock.Unlock()
+		next.CloseWithError(0, "eos")
+		return
+	}
+	t.transport, t.iface, t.local = next, iface, local
+	t.lock.Unlock()
+	t.wg.Add(1)
+	go t.read(next)
+	if err := transport.CloseWithError(0, "failover"); err != nil {
+		log.Printf("failed to close transport on %v: %v\n", from, err)
+	}
+	t.logFailover(start, from, fromLocal, iface, local, "reconnected")
+}
+
+func (t *FailoverTransport) logFailover(start time.Time, from string, fromLocal net.IP, to string, toLocal net.IP, method string) {
+	log.Printf("%v failover from %v (%v) to %v (%v): %v after %v\n", time.Now().Format(time.RFC3339Nano), from, fromLocal, to, toLocal, method, time.Since(start))
+}
+
+// read forwards messages from transport until it fails. Errors of transports
+// which were replaced are ignored.
+func (t *FailoverTransport) read(transport Transport) {
+	defer t.wg.Done()
+	for {
+		msg, err := transport.ReceiveMessage()
+		if err != nil {
+			if t.current() != transport || t.ctx.Err() != nil {
+				return
+			}
+			select {
+			case t.errCh <- err:
+			default:
+			}
+			return
+		}
+		select {
+		case t.in <- msg:
+		case <-t.ctx.Done():
+			return
+		}
+	}
+}
+
+// SendMessage sends msg on the current transport. Failures trigger a check of
+// the interfaces and return an error wrapping errMigrating, since the session
+// may be moved.
+func (t *FailoverTransport) SendMessage(msg []byte, sentCB func(error), ackLossCB func(bool)) error {
+	if t.ctx.Err() != nil {
+		return errSessionClosed
+	}
+	if err := t.current().SendMessage(msg, sentCB, ackLossCB); err != nil {
+		select {
+		case t.check <- struct{}{}:
+		default:
+		}
+		return fmt.Errorf("%w: %v", errMigrating, err)
+	}
+	return nil
+}
+
+// ReceiveMessage returns the next message of the current transport or its
+// error if it fails for other reasons than moving the session.
+func (t *FailoverTransport) ReceiveMessage() ([]byte, error) {
+	select {
+	case msg := <-t.in:
+		return msg, nil
+	case err := <-t.errCh:
+		return nil, err
+	case <-t.ctx.Done():
+		return nil, errSessionClosed
+	}
+}
+
+func (t *FailoverTransport) CloseWithError(code int, msg string) error {
+	var err error
+	t.closeOnce.Do(func() {
+		t.lock.Lock()
+		t.cancel()
+		transport := t.transport
+		t.lock.Unlock()
+		err = transport.CloseWithError(code, msg)
+		t.wg.Wait()
+	})
+	return err
+}
+
+func (t *FailoverTransport) Metrics() RTTStats {
+	return t.current().Metrics()
+}
+
+// HandleRTCP passes pkts on to the current transport, see rtcpHandler.
+func (t *FailoverTransport) HandleRTCP(pkts []rtcp.Packet) {
+	if h, ok := t.current().(rtcpHandler); ok {
+		h.HandleRTCP(pkts)
+	}
+}
+
+// watchLinks sends to events whenever a link or address changes, until ctx is
+// done.
+func watchLinks(ctx context.Context, events chan<- struct{}) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR,
+	}); err != nil {
+		return err
+	}
+	// Wake up regularly to notice when ctx is done.
+	timeout := unix.NsecToTimeval(time.Second.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		return err
+	}
+	buf := make([]byte, 1<<16)
+	for ctx.Err() == nil {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, msg := range msgs {
+			switch msg.Header.Type {
+			case unix.RTM_NEWLINK, unix.RTM_DELLINK, unix.RTM_NEWADDR, unix.RTM_DELADDR:
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+	return nil
+}
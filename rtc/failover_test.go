@@ -0,0 +1,63 @@
+package rtc
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailoverTransportReconnects(t *testing.T) {
+	var remotes []*pipeTransport
+	dialFrom := func(net.IP) (Transport, error) {
+		local, remote := newPipeTransports()
+		remotes = append(remotes, remote)
+		return local, nil
+	}
+	ft, err := NewFailoverTransport([]string{"lo"}, dialFrom)
+	assert.NoError(t, err)
+	defer ft.CloseWithError(0, "eos")
+	assert.Len(t, remotes, 1)
+
+	// Pretend the session is on an interface which went away.
+	ft.lock.Lock()
+	ft.iface = "wwan0"
+	ft.lock.Unlock()
+	ft.update()
+	assert.Len(t, remotes, 2)
+
+	_, err = remotes[0].ReceiveMessage()
+	assert.ErrorIs(t, err, errSessionClosed)
+	assert.NoError(t, ft.SendMessage([]byte{1}, nil, nil))
+	msg, err := remotes[1].ReceiveMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1}, msg)
+
+	assert.NoError(t, remotes[1].SendMessage([]byte{2}, nil, nil))
+	msg, err = ft.ReceiveMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2}, msg)
+}
+
+type brokenTransport struct {
+	*pipeTransport
+}
+
+func (t *brokenTransport) SendMessage([]byte, func(error), func(bool)) error {
+	return errors.New("network is unreachable")
+}
+
+func TestFailoverTransportReturnsSendErrors(t *testing.T) {
+	ft, err := NewFailoverTransport([]string{"lo"}, func(net.IP) (Transport, error) {
+		local, _ := newPipeTransports()
+		return &brokenTransport{pipeTransport: local}, nil
+	})
+	assert.NoError(t, err)
+	defer ft.CloseWithError(0, "eos")
+
+	// Failures of the current transport are returned, but don't close the
+	// session.
+	assert.ErrorIs(t, ft.SendMessage([]byte{1}, nil, nil), errMigrating)
+	assert.NoError(t, ft.ctx.Err())
+}
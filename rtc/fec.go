@@ -0,0 +1,454 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+)
+
+// flexFECPayloadType is the payload type of FlexFEC packets, RFC 8627.
+const flexFECPayloadType = 98
+
+// rtpHeaderSize is the size of the fixed RTP header, which FEC protects apart
+// from the sequence number and SSRC.
+const rtpHeaderSize = 12
+
+const (
+	// minFECGroup and maxFECGroup limit the number of media packets
+	// protected by a single FEC packet, and thereby the overhead to between
+	// about 2% and 50%.
+	minFECGroup = 2
+	maxFECGroup = 46
+	// fecAdaptInterval is how often the overhead is adapted to the loss
+	// rate and reported to the bitrate allocator.
+	fecAdaptInterval = time.Second
+	// fecHistorySize is the number of media packets per flow the receiver
+	// keeps for recovery.
+	fecHistorySize = 256
+	// maxPendingFEC is the number of FEC packets per flow the receiver keeps
+	// until they can recover a packet.
+	maxPendingFEC = 64
+)
+
+var errFECTooShort = errors.New("FEC packet too short")
+
+// fecAttribute marks FEC packets in the attributes of the RTP writer on the
+// sender and recovered packets in the attributes of the RTP reader on the
+// receiver.
+type fecAttribute struct{}
+
+func isFEC(pkt []byte) bool {
+	return len(pkt) >= 2 && pkt[1]&0x7f == flexFECPayloadType
+}
+
+// fecSSRC returns the SSRC of the FEC packets protecting the stream ssrc.
+func fecSSRC(ssrc uint32) uint32 {
+	return ssrc + 2
+}
+
+// fecGroupSize returns the number of media packets to protect with one FEC
+// packet for an overhead of FEC packets per media packet.
+func fecGroupSize(overhead float64) int {
+	if overhead <= 0 {
+		return maxFECGroup
+	}
+	n := int(math.Round(1 / overhead))
+	if n < minFECGroup {
+		return minFECGroup
+	}
+	if n > maxFECGroup {
+		return maxFECGroup
+	}
+	return n
+}
+
+// maskBit returns the index of the bit of the packet at offset i from the
+// base sequence number in the flexible mask, which starts with a k bit and
+// continues with another k bit after the first 15 and 46 offsets, RFC 8627,
+// Section 4.2.2.1.
+func maskBit(i int) int {
+	switch {
+	case i < 15:
+		return 1 + i
+	case i < 46:
+		return 17 + i - 15
+	}
+	return 48 + i - 46
+}
+
+// flexFEC returns the FEC header with a flexible mask followed by the repair
+// payload protecting pkts, which are consecutive RTP packets starting at
+// sequence number base, RFC 8627, Section 6.3.1.
+func flexFEC(base uint16, pkts [][]byte) []byte {
+	maskSize := 2
+	if len(pkts) > 15 {
+		maskSize = 6
+	}
+	longest := 0
+	for _, p := range pkts {
+		if len(p)-rtpHeaderSize > longest {
+			longest = len(p) - rtpHeaderSize
+		}
+	}
+	headerSize := 10 + maskSize
+	buf := make([]byte, headerSize+longest)
+	var length uint16
+	var ts uint32
+	for _, p := range pkts {
+		buf[0] ^= p[0]
+		buf[1] ^= p[1]
+		length ^= uint16(len(p) - rtpHeaderSize)
+		ts ^= binary.BigEndian.Uint32(p[4:8])
+		for j, b := range p[rtpHeaderSize:] {
+			buf[headerSize+j] ^= b
+		}
+	}
+	// R and F are zero, the version is not protected.
+	buf[0] &= 0x3f
+	binary.BigEndian.PutUint16(buf[2:4], length)
+	binary.BigEndian.PutUint32(buf[4:8], ts)
+	binary.BigEndian.PutUint16(buf[8:10], base)
+	mask := buf[10:headerSize]
+	for i := range pkts {
+		b := maskBit(i)
+		mask[b/8] |= 0x80 >> (b % 8)
+	}
+	// The k bit of the last part of the mask is set.
+	if maskSize == 2 {
+		mask[0] |= 0x80
+	} else {
+		mask[2] |= 0x80
+	}
+	return buf
+}
+
+// fecPacket is a parsed FlexFEC packet with a flexible mask.
+type fecPacket struct {
+	bits    [2]byte
+	length  uint16
+	ts      uint32
+	seqs    []uint16
+	payload []byte
+}
+
+func (p *fecPacket) unmarshal(pkt []byte) error {
+	var header rtp.Header
+	n, err := header.Unmarshal(pkt)
+	if err != nil {
+		return err
+	}
+	buf := pkt[n:]
+	if len(buf) < 12 {
+		return errFECTooShort
+	}
+	if buf[0]&0x40 != 0 {
+		return errors.New("FEC packet with fixed mask")
+	}
+	p.bits = [2]byte{buf[0], buf[1]}
+	p.length = binary.BigEndian.Uint16(buf[2:4])
+	p.ts = binary.BigEndian.Uint32(buf[4:8])
+	base := binary.BigEndian.Uint16(buf[8:10])
+	maskSize := 2
+	if buf[10]&0x80 == 0 {
+		maskSize = 6
+		if len(buf) < 16 {
+			return errFECTooShort
+		}
+		if buf[12]&0x80 == 0 {
+			maskSize = 14
+		}
+	}
+	if len(buf) < 10+maskSize {
+		return errFECTooShort
+	}
+	mask := buf[10 : 10+maskSize]
+	p.seqs = p.seqs[:0]
+	for i := 0; maskBit(i) < 8*maskSize; i++ {
+		b := maskBit(i)
+		if mask[b/8]&(0x80>>(b%8)) != 0 {
+			p.seqs = append(p.seqs, base+uint16(i))
+		}
+	}
+	p.payload = buf[10+maskSize:]
+	return nil
+}
+
+// recover returns the single packet of p missing from pkts, RFC 8627, Section
+// 6.3.2. pkts are all other packets p protects.
+func (p *fecPacket) recover(ssrc uint32, seq uint16, pkts [][]byte) ([]byte, error) {
+	bits := p.bits
+	length := p.length
+	ts := p.ts
+	payload := append([]byte(nil), p.payload...)
+	for _, q := range pkts {
+		bits[0] ^= q[0]
+		bits[1] ^= q[1]
+		length ^= uint16(len(q) - rtpHeaderSize)
+		ts ^= binary.BigEndian.Uint32(q[4:8])
+		for j, b := range q[rtpHeaderSize:] {
+			if j < len(payload) {
+				payload[j] ^= b
+			}
+		}
+	}
+	if int(length) > len(payload) {
+		return nil, errors.New("recovered packet longer than FEC payload")
+	}
+	buf := make([]byte, rtpHeaderSize+int(length))
+	buf[0] = 0x80 | bits[0]&0x3f
+	buf[1] = bits[1]
+	binary.BigEndian.PutUint16(buf[2:4], seq)
+	binary.BigEndian.PutUint32(buf[4:8], ts)
+	binary.BigEndian.PutUint32(buf[8:12], ssrc)
+	copy(buf[rtpHeaderSize:], payload)
+	return buf, nil
+}
+
+// withoutTWCC returns pkt without the TWCC header extension, since the
+// transport-wide sequence number of a lost packet would confuse TWCC
+// feedback.
+func withoutTWCC(pkt []byte) ([]byte, error) {
+	var p rtp.Packet
+	if err := p.Unmarshal(pkt); err != nil {
+		return nil, err
+	}
+	if !p.Extension || p.DelExtension(transportCCExtensionID) != nil {
+		return pkt, nil
+	}
+	p.Extension = len(p.Extensions) > 0
+	return p.Marshal()
+}
+
+type fecStream struct {
+	e      *fecEncoder
+	ssrc   uint32
+	writer interceptor.RTPWriter
+
+	lock  sync.Mutex
+	seq   uint16
+	base  uint16
+	group [][]byte
+}
+
+func (s *fecStream) write(header *rtp.Header, payload []byte, a interceptor.Attributes) (int, error) {
+	if rtx, _ := a.Get(rtxAttribute{}).(bool); rtx {
+		return s.writer.Write(header, payload, a)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	n, err := s.writer.Write(header, payload, a)
+	if err != nil {
+		return n, err
+	}
+	pkt, err := header.Marshal()
+	if err != nil {
+		return n, err
+	}
+	pkt = append(pkt, payload...)
+	groupSize := s.e.sent(len(pkt), false)
+
+	if len(s.group) > 0 && header.SequenceNumber != s.base+uint16(len(s.group)) {
+		s.group = s.group[:0]
+	}
+	if len(s.group) == 0 {
+		s.base = header.SequenceNumber
+	}
+	s.group = append(s.group, pkt)
+	// Groups end with frames, so that the receiver does not wait for the
+	// next frame to recover the last packets of a frame.
+	if len(s.group) < groupSize && !(header.Marker && len(s.group) >= minFECGroup) {
+		return n, nil
+	}
+	fec := flexFEC(s.base, s.group)
+	s.group = s.group[:0]
+	fecHeader := rtp.Header{
+		Version:        2,
+		PayloadType:    flexFECPayloadType,
+		SequenceNumber: s.seq,
+		Timestamp:      header.Timestamp,
+		SSRC:           fecSSRC(s.ssrc),
+		CSRC:           []uint32{s.ssrc},
+	}
+	s.seq++
+	if _, err := s.writer.Write(&fecHeader, fec, interceptor.Attributes{fecAttribute{}: true}); err != nil {
+		log.Printf("failed to send FEC packet of SSRC %v: %v\n", s.ssrc, err)
+	}
+	s.e.sent(fecHeader.MarshalSize()+len(fec), true)
+	return n, nil
+}
+
+type fecEncoderFactory struct {
+	m          Metricer
+	overhead   float64
+	onOverhead func(float64)
+}
+
+func (f *fecEncoderFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return &fecEncoder{
+		m:          f.m,
+		overhead:   f.overhead,
+		onOverhead: f.onOverhead,
+		group:      fecGroupSize(f.overhead),
+	}, nil
+}
+
+// fecEncoder protects groups of consecutive packets of every stream with a
+// FlexFEC packet, RFC 8627. Unless the overhead is fixed, it protects
+// smaller groups the more packets the transport loses. The ratio of FEC to
+// media bytes is reported to onOverhead, so that the FEC bitrate is
+// subtracted from the bitrate of the media sources.
+type fecEncoder struct {
+	interceptor.NoOp
+	m          Metricer
+	overhead   float64
+	onOverhead func(float64)
+
+	lock       sync.Mutex
+	group      int
+	lastAdapt  time.Time
+	lost       uint64
+	packets    int
+	mediaBytes int
+	fecBytes   int
+}
+
+func (e *fecEncoder) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	s := &fecStream{
+		e:      e,
+		ssrc:   info.SSRC,
+		writer: writer,
+	}
+	return interceptor.RTPWriterFunc(s.write)
+}
+
+// sent records a sent packet and returns the current group size.
+func (e *fecEncoder) sent(size int, fec bool) int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.packets++
+	if fec {
+		e.fecBytes += size
+	} else {
+		e.mediaBytes += size
+	}
+	if now := time.Now(); now.Sub(e.lastAdapt) >= fecAdaptInterval {
+		e.adapt(now, e.m.Metrics())
+	}
+	return e.group
+}
+
+// adapt sets the group size for a FEC overhead of twice the loss rate, unless
+// the overhead is fixed, and reports the overhead since the last call. The
+// caller must hold e.lock.
+func (e *fecEncoder) adapt(now time.Time, stats RTTStats) {
+	loss := stats.LossRate
+	if loss == 0 && e.packets > 0 && stats.Lost > e.lost {
+		loss = float64(stats.Lost-e.lost) / float64(e.packets)
+	}
+	if !e.lastAdapt.IsZero() && e.overhead == 0 {
+		if group := fecGroupSize(2 * loss); group != e.group {
+			log.Printf("protecting groups of %v packets with FEC, loss rate %.3f\n", group, loss)
+			e.group = group
+		}
+	}
+	if e.mediaBytes > 0 && e.onOverhead != nil {
+		e.onOverhead(float64(e.fecBytes) / float64(e.mediaBytes))
+	}
+	e.lost = stats.Lost
+	e.packets, e.mediaBytes, e.fecBytes = 0, 0, 0
+	e.lastAdapt = now
+}
+
+// fecDecoder recovers the media packets of a flow from FlexFEC packets.
+type fecDecoder struct {
+	ssrc    uint32
+	history [fecHistorySize][]byte
+	pending []*fecPacket
+}
+
+func newFECDecoder(ssrc uint32) *fecDecoder {
+	return &fecDecoder{ssrc: ssrc}
+}
+
+func (d *fecDecoder) get(seq uint16) []byte {
+	pkt := d.history[seq%fecHistorySize]
+	if pkt == nil || binary.BigEndian.Uint16(pkt[2:4]) != seq {
+		return nil
+	}
+	return pkt
+}
+
+// add records the media packet pkt and returns the packets recovered with it.
+// Retransmissions must not be added, they lack the TWCC header extension
+// the FEC packets protect.
+func (d *fecDecoder) add(pkt []byte) [][]byte {
+	if len(pkt) < rtpHeaderSize {
+		return nil
+	}
+	seq := binary.BigEndian.Uint16(pkt[2:4])
+	d.history[seq%fecHistorySize] = append([]byte(nil), pkt...)
+	return d.recover()
+}
+
+// addFEC records the FEC packet pkt and returns the packets recovered with
+// it.
+func (d *fecDecoder) addFEC(pkt []byte) ([][]byte, error) {
+	p := &fecPacket{}
+	if err := p.unmarshal(pkt); err != nil {
+		return nil, err
+	}
+	d.pending = append(d.pending, p)
+	if len(d.pending) > maxPendingFEC {
+		d.pending = d.pending[1:]
+	}
+	return d.recover(), nil
+}
+
+// recover returns all packets which can be recovered, since a pending FEC
+// packet misses only one of the packets it protects. FEC packets which miss
+// none are dropped.
+func (d *fecDecoder) recover() [][]byte {
+	var recovered [][]byte
+	for progress := true; progress; {
+		progress = false
+		pending := d.pending[:0]
+		for _, p := range d.pending {
+			var missing []uint16
+			var pkts [][]byte
+			for _, seq := range p.seqs {
+				if pkt := d.get(seq); pkt != nil {
+					pkts = append(pkts, pkt)
+				} else {
+					missing = append(missing, seq)
+				}
+			}
+			switch len(missing) {
+			case 0:
+				continue
+			case 1:
+				pkt, err := p.recover(d.ssrc, missing[0], pkts)
+				if err != nil {
+					log.Printf("failed to recover packet %v of SSRC %v: %v\n", missing[0], d.ssrc, err)
+					continue
+				}
+				d.history[missing[0]%fecHistorySize] = pkt
+				progress = true
+				if pkt, err = withoutTWCC(pkt); err != nil {
+					log.Printf("failed to parse recovered packet %v of SSRC %v: %v\n", missing[0], d.ssrc, err)
+					continue
+				}
+				recovered = append(recovered, pkt)
+				continue
+			}
+			pending = append(pending, p)
+		}
+		d.pending = pending
+	}
+	return recovered
+}
@@ -0,0 +1,95 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlexFEC(t *testing.T) {
+	i, err := (&fecEncoderFactory{m: &staticMetricer{}, overhead: 0.25}).NewInterceptor("")
+	assert.NoError(t, err)
+	var media, fec [][]byte
+	writer := i.BindLocalStream(&interceptor.StreamInfo{SSRC: 1}, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, a interceptor.Attributes) (int, error) {
+		buf, err := (&rtp.Packet{Header: *header, Payload: payload}).Marshal()
+		assert.NoError(t, err)
+		if isFEC, _ := a.Get(fecAttribute{}).(bool); isFEC {
+			fec = append(fec, buf)
+		} else {
+			media = append(media, buf)
+		}
+		return len(payload), nil
+	}))
+	for seq := uint16(65534); seq != 6; seq++ {
+		header := rtp.Header{Version: 2, PayloadType: 96, SSRC: 1, SequenceNumber: seq, Timestamp: uint32(seq / 3), Marker: seq == 1}
+		assert.NoError(t, header.SetExtension(transportCCExtensionID, []byte{byte(seq), 0}))
+		_, err := writer.Write(&header, make([]byte, 10+int(seq%5)), nil)
+		assert.NoError(t, err)
+	}
+	// The first group ends with the frame, the second after four packets.
+	assert.Len(t, media, 8)
+	assert.Len(t, fec, 2)
+
+	var p fecPacket
+	assert.NoError(t, p.unmarshal(fec[0]))
+	assert.Equal(t, []uint16{65534, 65535, 0, 1}, p.seqs)
+
+	// Lost packets are recovered without the TWCC header extension once the
+	// FEC packet and all other packets arrived.
+	d := newFECDecoder(1)
+	assert.Empty(t, d.add(media[0]))
+	assert.Empty(t, d.add(media[2]))
+	recovered, err := d.addFEC(fec[0])
+	assert.NoError(t, err)
+	assert.Empty(t, recovered)
+	recovered = d.add(media[3])
+	assert.Len(t, recovered, 1)
+	var lost rtp.Packet
+	assert.NoError(t, lost.Unmarshal(media[1]))
+	lost.Extension = false
+	buf, err := lost.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, buf, recovered[0])
+
+	// FEC packets arriving after the other packets recover immediately and
+	// are dropped once nothing is missing.
+	for _, pkt := range media[4:7] {
+		assert.Empty(t, d.add(pkt))
+	}
+	recovered, err = d.addFEC(fec[1])
+	assert.NoError(t, err)
+	assert.Len(t, recovered, 1)
+	assert.Equal(t, uint16(5), binary.BigEndian.Uint16(recovered[0][2:4]))
+	assert.Empty(t, d.pending)
+}
+
+func TestFECAdapt(t *testing.T) {
+	var overhead float64
+	i, err := (&fecEncoderFactory{m: &staticMetricer{}, onOverhead: func(o float64) {
+		overhead = o
+	}}).NewInterceptor("")
+	assert.NoError(t, err)
+	e := i.(*fecEncoder)
+	assert.Equal(t, maxFECGroup, e.group)
+
+	now := time.Now()
+	e.adapt(now, RTTStats{Lost: 10})
+	e.packets, e.mediaBytes, e.fecBytes = 100, 10000, 1000
+	e.adapt(now.Add(fecAdaptInterval), RTTStats{Lost: 15})
+	assert.Equal(t, 10, e.group)
+	assert.Equal(t, 0.1, overhead)
+
+	e.adapt(now.Add(2*fecAdaptInterval), RTTStats{Lost: 15, LossRate: 0.5})
+	assert.Equal(t, minFECGroup, e.group)
+
+	// The FEC bitrate is subtracted from the bitrate of the sources.
+	src := &bitrateSource{}
+	a := &bitrateAllocator{target: 1_250_000}
+	a.addPipeline(src, FlowConfig{})
+	a.setFECOverhead(0.25)
+	assert.Equal(t, uint(1_000_000), src.getBitRate())
+}
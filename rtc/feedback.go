@@ -0,0 +1,377 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/twcc"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// packetReport is the state of a sent packet according to congestion control
+// feedback.
+type packetReport struct {
+	// twcc is set if seq is a transport-wide sequence number, in which case
+	// ssrc is unknown.
+	twcc     bool
+	ssrc     uint32
+	seq      uint16
+	received bool
+	// arrival is the time the packet arrived in the clock of the receiver,
+	// relative to an unknown epoch. It is only valid if timed is set.
+	arrival time.Duration
+	timed   bool
+	ecn     uint8
+}
+
+// feedbackReports converts the TWCC and RFC 8888 feedback in pkts to packet
+// reports. Other packets are ignored.
+func feedbackReports(pkts []rtcp.Packet) []packetReport {
+	var reports []packetReport
+	for _, pkt := range pkts {
+		switch p := pkt.(type) {
+		case *rtcp.TransportLayerCC:
+			reports = append(reports, twccReports(p)...)
+		case *rtcp.RawPacket:
+			r, err := ccfbReports(*p)
+			if err != nil {
+				if !errors.Is(err, errNotCCFB) {
+					log.Printf("failed to parse RFC 8888 feedback: %v\n", err)
+				}
+				continue
+			}
+			reports = append(reports, r...)
+		}
+	}
+	return reports
+}
+
+func twccReports(p *rtcp.TransportLayerCC) []packetReport {
+	statuses := make([]uint16, 0, p.PacketStatusCount)
+	for _, chunk := range p.PacketChunks {
+		switch c := chunk.(type) {
+		case *rtcp.RunLengthChunk:
+			for i := uint16(0); i < c.RunLength; i++ {
+				statuses = append(statuses, c.PacketStatusSymbol)
+			}
+		case *rtcp.StatusVectorChunk:
+			statuses = append(statuses, c.SymbolList...)
+		}
+	}
+	if len(statuses) > int(p.PacketStatusCount) {
+		statuses = statuses[:p.PacketStatusCount]
+	}
+
+	reports := make([]packetReport, 0, len(statuses))
+	arrival := time.Duration(p.ReferenceTime) * 64 * time.Millisecond
+	deltas := p.RecvDeltas
+	for i, status := range statuses {
+		r := packetReport{
+			twcc: true,
+			seq:  p.BaseSequenceNumber + uint16(i),
+		}
+		switch status {
+		case rtcp.TypeTCCPacketReceivedSmallDelta, rtcp.TypeTCCPacketReceivedLargeDelta:
+			if len(deltas) == 0 {
+				break
+			}
+			arrival += time.Duration(deltas[0].Delta) * time.Microsecond
+			deltas = deltas[1:]
+			r.received, r.timed, r.arrival = true, true, arrival
+		case rtcp.TypeTCCPacketReceivedWithoutDelta:
+			r.received = true
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// ccfbReports parses an RFC 8888 congestion control feedback packet.
+func ccfbReports(b []byte) ([]packetReport, error) {
+	var p CCFeedback
+	if err := p.Unmarshal(b); err != nil {
+		return nil, err
+	}
+	// The report timestamp holds the middle 32 bits of an NTP timestamp.
+	reportTime := time.Duration(p.ReportTimestamp) * time.Second / 65536
+	var reports []packetReport
+	for _, block := range p.ReportBlocks {
+		for i, m := range block.Reports {
+			r := packetReport{
+				ssrc:     block.MediaSSRC,
+				seq:      block.BeginSequence + uint16(i),
+				received: m.Received,
+				ecn:      m.ECN,
+			}
+			if m.Received && m.ArrivalTimeOffset != CCFBArrivalUnavailable {
+				r.timed = true
+				r.arrival = reportTime - time.Duration(m.ArrivalTimeOffset)*time.Second/1024
+			}
+			reports = append(reports, r)
+		}
+	}
+	return reports, nil
+}
+
+// feedbackKind is the congestion control feedback format a rate controller
+// reads.
+type feedbackKind int
+
+const (
+	twccFeedback feedbackKind = iota
+	ccfbFeedback
+)
+
+func (k feedbackKind) String() string {
+	if k == twccFeedback {
+		return "TWCC"
+	}
+	return "RFC 8888"
+}
+
+type rtpSeq struct {
+	ssrc uint32
+	seq  uint16
+}
+
+// feedbackTranslatorFactory wraps the interceptors of factory in
+// feedbackTranslators.
+type feedbackTranslatorFactory struct {
+	factory interceptor.Factory
+	to      feedbackKind
+}
+
+func (f *feedbackTranslatorFactory) NewInterceptor(id string) (interceptor.Interceptor, error) {
+	i, err := f.factory.NewInterceptor(id)
+	if err != nil {
+		return nil, err
+	}
+	return &feedbackTranslator{
+		Interceptor: i,
+		to:          f.to,
+		twccSeqs:    map[rtpSeq]uint16{},
+		rtpSeqs:     map[uint16]rtpSeq{},
+		recorder:    twcc.NewRecorder(0),
+		generator:   newCCFBGenerator(CCFBDraft),
+	}, nil
+}
+
+// feedbackTranslator feeds the RTCP of the wrapped interceptor with feedback
+// of the kind it reads: TWCC feedback is converted to RFC 8888 feedback and
+// the other way round, other packets are passed on unchanged. Packets are
+// matched by the transport-wide sequence number header extension, which has
+// to be added by an interceptor registered after the translator.
+type feedbackTranslator struct {
+	interceptor.Interceptor
+	to feedbackKind
+
+	lock sync.Mutex
+	// twccSeqs and rtpSeqs map the RTP sequence numbers of sent packets to
+	// their transport-wide sequence numbers and back.
+	twccSeqs map[rtpSeq]uint16
+	rtpSeqs  map[uint16]rtpSeq
+	// recorder builds TWCC feedback from RFC 8888 feedback.
+	recorder *twcc.Recorder
+	recorded int
+	// generator builds RFC 8888 feedback from TWCC feedback.
+	generator *ccfbGenerator
+	logOnce   sync.Once
+}
+
+func (t *feedbackTranslator) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	var twccID uint8
+	for _, e := range info.RTPHeaderExtensions {
+		if e.URI == transportCCURI {
+			twccID = uint8(e.ID)
+		}
+	}
+	inner := t.Interceptor.BindLocalStream(info, writer)
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		if ext := header.GetExtension(twccID); twccID != 0 && len(ext) >= 2 {
+			t.remember(rtpSeq{ssrc: header.SSRC, seq: header.SequenceNumber}, binary.BigEndian.Uint16(ext))
+		}
+		return inner.Write(header, payload, attributes)
+	})
+}
+
+func (t *feedbackTranslator) remember(key rtpSeq, twccSeq uint16) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	// Transport-wide sequence numbers wrap, which also bounds the history.
+	if old, ok := t.rtpSeqs[twccSeq]; ok {
+		delete(t.twccSeqs, old)
+	}
+	t.rtpSeqs[twccSeq] = key
+	t.twccSeqs[key] = twccSeq
+}
+
+func (t *feedbackTranslator) UnbindLocalStream(info *interceptor.StreamInfo) {
+	t.lock.Lock()
+	t.generator.remove(info.SSRC)
+	t.lock.Unlock()
+	t.Interceptor.UnbindLocalStream(info)
+}
+
+// BindRTCPReader passes the RTCP read by reader to the RTCP reader of the
+// wrapped interceptor after translating the feedback. Translated feedback
+// packets are read one at a time.
+func (t *feedbackTranslator) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	inner := t.Interceptor.BindRTCPReader(interceptor.RTCPReaderFunc(func(in []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return len(in), a, nil
+	}))
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		pkts, err := attr.GetRTCPPackets(b[:n])
+		if err != nil {
+			return 0, nil, err
+		}
+		kept, translated, ok := t.translate(pkts)
+		if !ok {
+			if _, _, err := inner.Read(b[:n], nil); err != nil {
+				return 0, nil, err
+			}
+			return n, attr, nil
+		}
+		if len(kept) > 0 {
+			buf, err := rtcp.Marshal(kept)
+			if err != nil {
+				return 0, nil, err
+			}
+			if _, _, err := inner.Read(buf, nil); err != nil {
+				return 0, nil, err
+			}
+		}
+		for _, buf := range translated {
+			if _, _, err := inner.Read(buf, nil); err != nil {
+				return 0, nil, err
+			}
+		}
+		return n, attr, nil
+	})
+}
+
+// translate splits pkts into the packets which are passed on unchanged and
+// the marshaled feedback translated from the others. ok is false if pkts
+// contain no feedback to translate. Feedback which can't be translated yet
+// is dropped.
+func (t *feedbackTranslator) translate(pkts []rtcp.Packet) (kept []rtcp.Packet, translated [][]byte, ok bool) {
+	var reports []packetReport
+	for _, pkt := range pkts {
+		switch p := pkt.(type) {
+		case *rtcp.TransportLayerCC:
+			if t.to == ccfbFeedback {
+				reports = append(reports, twccReports(p)...)
+				ok = true
+				continue
+			}
+		case *rtcp.RawPacket:
+			if t.to == twccFeedback {
+				r, err := ccfbReports(*p)
+				if err == nil {
+					reports = append(reports, r...)
+					ok = true
+					continue
+				}
+				if !errors.Is(err, errNotCCFB) {
+					log.Printf("failed to parse RFC 8888 feedback: %v\n", err)
+				}
+			}
+		}
+		kept = append(kept, pkt)
+	}
+	if !ok {
+		return pkts, nil, false
+	}
+	t.logOnce.Do(func() {
+		log.Printf("translating feedback to %v for the rate controller\n", t.to)
+	})
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.to == twccFeedback {
+		if fb := t.toTWCC(reports); len(fb) > 0 {
+			buf, err := rtcp.Marshal(fb)
+			if err != nil {
+				log.Printf("failed to marshal translated TWCC feedback: %v\n", err)
+			} else {
+				translated = append(translated, buf)
+			}
+		}
+	} else {
+		for _, fb := range t.toCCFB(reports) {
+			buf, err := fb.Marshal()
+			if err != nil {
+				log.Printf("failed to marshal translated RFC 8888 feedback: %v\n", err)
+				continue
+			}
+			translated = append(translated, buf)
+		}
+	}
+	return kept, translated, true
+}
+
+// toTWCC records the packets reported received by RFC 8888 feedback. Lost
+// packets are the gaps between the recorded ones. The caller must hold
+// t.lock.
+func (t *feedbackTranslator) toTWCC(reports []packetReport) []rtcp.Packet {
+	for _, r := range reports {
+		if !r.received || !r.timed {
+			continue
+		}
+		key := rtpSeq{ssrc: r.ssrc, seq: r.seq}
+		twccSeq, ok := t.twccSeqs[key]
+		if !ok {
+			continue
+		}
+		// Feedback may report packets more than once, only the first
+		// report is used.
+		delete(t.twccSeqs, key)
+		t.recorder.Record(r.ssrc, twccSeq, r.arrival.Microseconds())
+		t.recorded++
+	}
+	// The recorder drops single packets instead of reporting them.
+	if t.recorded < 2 {
+		return nil
+	}
+	t.recorded = 0
+	return t.recorder.BuildFeedbackPacket()
+}
+
+// toCCFB builds RFC 8888 feedback from TWCC feedback. The report timestamp
+// is the latest reported arrival, since TWCC feedback does not carry the
+// time it was sent. The caller must hold t.lock.
+func (t *feedbackTranslator) toCCFB(reports []packetReport) []*CCFeedback {
+	// Arrival times of TWCC feedback are relative to an unknown epoch,
+	// any fixed epoch works to convert them back.
+	epoch := time.Unix(0, 0)
+	var latest time.Duration
+	added := false
+	for _, r := range reports {
+		if !r.received || !r.timed {
+			continue
+		}
+		key, ok := t.rtpSeqs[r.seq]
+		if !ok {
+			continue
+		}
+		t.generator.add(key.ssrc, key.seq, epoch.Add(r.arrival), 0)
+		if !added || r.arrival > latest {
+			latest, added = r.arrival, true
+		}
+	}
+	if !added {
+		return nil
+	}
+	return t.generator.feedback(epoch.Add(latest))
+}
@@ -0,0 +1,197 @@
+package rtc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/twcc"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCCFBReports(t *testing.T) {
+	// Feedback as sent by SCReAM: format 0, three reports counted as two,
+	// report timestamp of 2 seconds.
+	pkt := []byte{
+		0x80, 205, 0x00, 0x06,
+		0x00, 0x00, 0x00, 0x01, // sender SSRC
+		0x00, 0x00, 0x00, 0x02, // media SSRC
+		0x00, 0x0a, 0x00, 0x02, // begin 10, 3 reports
+		0x84, 0x00, // received, 1s before report timestamp
+		0x00, 0x00, // lost
+		0xff, 0xff, // received, CE, no arrival time
+		0x00, 0x00, // padding
+		0x00, 0x02, 0x00, 0x00, // report timestamp
+	}
+	reports := feedbackReports([]rtcp.Packet{(*rtcp.RawPacket)(&pkt)})
+	assert.Equal(t, []packetReport{
+		{ssrc: 2, seq: 10, received: true, timed: true, arrival: time.Second},
+		{ssrc: 2, seq: 11},
+		{ssrc: 2, seq: 12, received: true, ecn: ecnCE},
+	}, reports)
+
+	_, err := ccfbReports(pkt[:20])
+	assert.ErrorIs(t, err, errInvalidCCFB)
+}
+
+func TestTWCCReports(t *testing.T) {
+	reports := feedbackReports([]rtcp.Packet{&rtcp.TransportLayerCC{
+		BaseSequenceNumber: 65535,
+		PacketStatusCount:  3,
+		ReferenceTime:      1,
+		PacketChunks: []rtcp.PacketStatusChunk{
+			&rtcp.StatusVectorChunk{
+				SymbolSize: rtcp.TypeTCCSymbolSizeTwoBit,
+				SymbolList: []uint16{
+					rtcp.TypeTCCPacketReceivedSmallDelta,
+					rtcp.TypeTCCPacketNotReceived,
+					rtcp.TypeTCCPacketReceivedLargeDelta,
+				},
+			},
+		},
+		RecvDeltas: []*rtcp.RecvDelta{
+			{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 1000},
+			{Type: rtcp.TypeTCCPacketReceivedLargeDelta, Delta: -500},
+		},
+	}})
+	assert.Equal(t, []packetReport{
+		{twcc: true, seq: 65535, received: true, timed: true, arrival: 65 * time.Millisecond},
+		{twcc: true, seq: 0},
+		{twcc: true, seq: 1, received: true, timed: true, arrival: 64500 * time.Microsecond},
+	}, reports)
+}
+
+func TestLocalTWCCGenerator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reports := make(chan []byte)
+	acks := make(chan ackedPkt)
+	m := &staticMetricer{LatestRTT: 40 * time.Millisecond}
+	go newLocalTWCCGenerator(m, reports, acks).Run(ctx)
+
+	sent := time.Now()
+	acks <- ackedPkt{sentTS: sent, seqNr: 1}
+	for i, seq := range []uint16{5, 6, 8} {
+		acks <- ackedPkt{
+			sentTS:    sent.Add(time.Duration(i*i) * 10 * time.Millisecond),
+			seqNr:     uint16(i),
+			twccSeqNr: seq,
+			hasTWCC:   true,
+		}
+	}
+
+	pkts, err := rtcp.Unmarshal(<-reports)
+	assert.NoError(t, err)
+	r := feedbackReports(pkts)
+	assert.Len(t, r, 4)
+	assert.Equal(t, []uint16{5, 6, 7, 8}, []uint16{r[0].seq, r[1].seq, r[2].seq, r[3].seq})
+	assert.Equal(t, []bool{true, true, false, true}, []bool{r[0].received, r[1].received, r[2].received, r[3].received})
+	assert.Equal(t, 10*time.Millisecond, r[1].arrival-r[0].arrival)
+	assert.Equal(t, 40*time.Millisecond, r[3].arrival-r[0].arrival)
+}
+
+// rtcpRecorder is an interceptor which records the RTCP it reads.
+type rtcpRecorder struct {
+	interceptor.NoOp
+	reads [][]byte
+}
+
+func (r *rtcpRecorder) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return r, nil
+}
+
+func (r *rtcpRecorder) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		r.reads = append(r.reads, append([]byte{}, b[:n]...))
+		return n, attr, err
+	})
+}
+
+// sendTranslated sends packets with sequence numbers 10 to 12 and
+// transport-wide sequence numbers 100 to 102 through a feedbackTranslator
+// and returns it with the RTCP reader bound to it.
+func sendTranslated(t *testing.T, to feedbackKind) (*rtcpRecorder, interceptor.RTCPReader) {
+	inner := &rtcpRecorder{}
+	i, err := (&feedbackTranslatorFactory{factory: inner, to: to}).NewInterceptor("")
+	assert.NoError(t, err)
+	writer := i.BindLocalStream(&interceptor.StreamInfo{
+		SSRC:                1,
+		RTPHeaderExtensions: []interceptor.RTPHeaderExtension{{URI: transportCCURI, ID: transportCCExtensionID}},
+	}, interceptor.RTPWriterFunc(func(_ *rtp.Header, _ []byte, _ interceptor.Attributes) (int, error) {
+		return 0, nil
+	}))
+	for i := uint16(0); i < 3; i++ {
+		header := &rtp.Header{SSRC: 1, SequenceNumber: 10 + i}
+		assert.NoError(t, header.SetExtension(transportCCExtensionID, []byte{0, byte(100 + i)}))
+		_, err := writer.Write(header, nil, nil)
+		assert.NoError(t, err)
+	}
+	reader := i.BindRTCPReader(interceptor.RTCPReaderFunc(func(in []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return len(in), a, nil
+	}))
+	return inner, reader
+}
+
+func TestFeedbackTranslatorToCCFB(t *testing.T) {
+	inner, reader := sendTranslated(t, ccfbFeedback)
+
+	recorder := twcc.NewRecorder(0)
+	recorder.Record(1, 100, 1_000_000)
+	recorder.Record(1, 102, 2_000_000)
+	buf, err := rtcp.Marshal(recorder.BuildFeedbackPacket())
+	assert.NoError(t, err)
+	_, _, err = reader.Read(buf, nil)
+	assert.NoError(t, err)
+
+	assert.Len(t, inner.reads, 1)
+	var fb CCFeedback
+	assert.NoError(t, fb.Unmarshal(inner.reads[0]))
+	assert.Equal(t, CCFBDraft, fb.Format)
+	assert.Equal(t, []CCFeedbackReportBlock{{
+		MediaSSRC:     1,
+		BeginSequence: 10,
+		Reports: []CCFeedbackReport{
+			{Received: true, ArrivalTimeOffset: 1024},
+			{},
+			{Received: true},
+		},
+	}}, fb.ReportBlocks)
+}
+
+func TestFeedbackTranslatorToTWCC(t *testing.T) {
+	inner, reader := sendTranslated(t, twccFeedback)
+
+	fb := &CCFeedback{
+		ReportBlocks: []CCFeedbackReportBlock{{
+			MediaSSRC:     1,
+			BeginSequence: 10,
+			Reports: []CCFeedbackReport{
+				{Received: true, ArrivalTimeOffset: 1024},
+				{},
+				{Received: true},
+			},
+		}},
+		ReportTimestamp: 2 << 16,
+	}
+	buf, err := fb.Marshal()
+	assert.NoError(t, err)
+	rr, err := (&rtcp.ReceiverReport{SSRC: 2}).Marshal()
+	assert.NoError(t, err)
+	_, _, err = reader.Read(append(rr, buf...), nil)
+	assert.NoError(t, err)
+
+	// The receiver report is passed on separately.
+	assert.Len(t, inner.reads, 2)
+	assert.Equal(t, rr, inner.reads[0])
+	pkts, err := rtcp.Unmarshal(inner.reads[1])
+	assert.NoError(t, err)
+	r := feedbackReports(pkts)
+	assert.Len(t, r, 3)
+	assert.Equal(t, []uint16{100, 101, 102}, []uint16{r[0].seq, r[1].seq, r[2].seq})
+	assert.Equal(t, []bool{true, false, true}, []bool{r[0].received, r[1].received, r[2].received})
+	assert.Equal(t, time.Second, r[2].arrival-r[0].arrival)
+}
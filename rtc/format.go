@@ -10,12 +10,33 @@ import (
 )
 
 type rtpFormatter struct {
-	seqnr unwrapper
+	seqnr    unwrapper
+	rtxSeqnr unwrapper
+	fecSeqnr unwrapper
 }
 
-func (f *rtpFormatter) rtpFormat(pkt *rtp.Packet, _ interceptor.Attributes) string {
+func (f *rtpFormatter) rtpFormat(pkt *rtp.Packet, attr interceptor.Attributes) string {
 	var twcc rtp.TransportCCExtension
-	unwrappedSeqNr := f.seqnr.unwrap(pkt.SequenceNumber)
+	// Retransmissions are restored by the receiver but keep their own
+	// sequence numbers on the sender, like FEC packets.
+	rtx, _ := attr.Get(rtxAttribute{}).(bool)
+	fec, _ := attr.Get(fecAttribute{}).(bool)
+	rtxColumn, fecColumn := 0, 0
+	if rtx {
+		rtxColumn = 1
+	}
+	if fec {
+		fecColumn = 1
+	}
+	var unwrappedSeqNr int64
+	switch {
+	case rtx && pkt.PayloadType == rtxPayloadType:
+		unwrappedSeqNr = f.rtxSeqnr.unwrap(pkt.SequenceNumber)
+	case fec && pkt.PayloadType == flexFECPayloadType:
+		unwrappedSeqNr = f.fecSeqnr.unwrap(pkt.SequenceNumber)
+	default:
+		unwrappedSeqNr = f.seqnr.unwrap(pkt.SequenceNumber)
+	}
 	var twccNr uint16
 	if len(pkt.GetExtensionIDs()) > 0 {
 		ext := pkt.GetExtension(pkt.GetExtensionIDs()[0])
@@ -24,7 +45,7 @@ func (f *rtpFormatter) rtpFormat(pkt *rtp.Packet, _ interceptor.Attributes) stri
 		}
 		twccNr = twcc.TransportSequence
 	}
-	return fmt.Sprintf("%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+	return fmt.Sprintf("%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
 		time.Now().Format(time.RFC3339Nano),
 		pkt.PayloadType,
 		pkt.SSRC,
@@ -34,19 +55,33 @@ func (f *rtpFormatter) rtpFormat(pkt *rtp.Packet, _ interceptor.Attributes) stri
 		pkt.MarshalSize(),
 		twccNr,
 		unwrappedSeqNr,
+		rtxColumn,
+		fecColumn,
 	)
 }
 
-func rtcpFormat(pkts []rtcp.Packet, _ interceptor.Attributes) string {
+func rtcpFormat(pkts []rtcp.Packet, attr interceptor.Attributes) string {
 	now := time.Now()
 	size := 0
+	nacks := 0
 	for _, pkt := range pkts {
+		if nack, ok := pkt.(*rtcp.TransportLayerNack); ok {
+			for _, pair := range nack.Nacks {
+				nacks += len(pair.PacketList())
+			}
+		}
 		switch feedback := pkt.(type) {
 		case *rtcp.TransportLayerCC:
 			size += int(feedback.Len())
 		case *rtcp.RawPacket:
 			size += int(len(*feedback))
+		case *rtcp.SenderReport, *rtcp.ReceiverReport, *CCFeedback, *rtcp.TransportLayerNack:
+			buf, err := feedback.Marshal()
+			if err == nil {
+				size += len(buf)
+			}
 		}
 	}
-	return fmt.Sprintf("%v\t%v\n", now.Format(time.RFC3339Nano), size)
+	rtts, _ := attr.Get(rtcpRTTAttribute{}).(map[uint32]time.Duration)
+	return fmt.Sprintf("%v\t%v\t%v\t%v\n", now.Format(time.RFC3339Nano), size, nacks, formatReports(pkts, rtts))
 }
@@ -0,0 +1,36 @@
+package rtc
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+var errHandshakeFailed = errors.New("QUIC handshake failed")
+
+// WaitHandshake blocks until the handshake of session completes and logs its
+// type and the time it took since start.
+func WaitHandshake(session quic.EarlySession, start time.Time) error {
+	select {
+	case <-session.HandshakeComplete().Done():
+	case <-session.Context().Done():
+		return errHandshakeFailed
+	}
+	log.Printf("%v QUIC handshake completed after %v\n", handshakeType(session.ConnectionState()), time.Since(start))
+	return nil
+}
+
+// handshakeType returns "0-RTT" if early data was accepted, "resumed" if a
+// session ticket was used without early data and "full" otherwise.
+func handshakeType(state quic.ConnectionState) string {
+	switch {
+	case state.TLS.Used0RTT:
+		return "0-RTT"
+	case state.TLS.DidResume:
+		return "resumed"
+	default:
+		return "full"
+	}
+}
@@ -0,0 +1,97 @@
+package rtc
+
+import (
+	"context"
+	"crypto/tls"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// earlyDatagramTracer counts the DATAGRAM frames sent in 0-RTT packets.
+type earlyDatagramTracer struct {
+	*RTTTracer
+	datagrams int32
+}
+
+func (t *earlyDatagramTracer) TracerForConnection(context.Context, logging.Perspective, logging.ConnectionID) logging.ConnectionTracer {
+	return &earlyDatagramConnectionTracer{ConnectionRTTTracer: &ConnectionRTTTracer{t: t.RTTTracer}, t: t}
+}
+
+type earlyDatagramConnectionTracer struct {
+	*ConnectionRTTTracer
+	t *earlyDatagramTracer
+}
+
+func (c *earlyDatagramConnectionTracer) SentPacket(hdr *logging.ExtendedHeader, _ logging.ByteCount, _ *logging.AckFrame, frames []logging.Frame) {
+	if logging.PacketTypeFromHeader(&hdr.Header) != logging.PacketType0RTT {
+		return
+	}
+	for _, f := range frames {
+		if _, ok := f.(*logging.DatagramFrame); ok {
+			atomic.AddInt32(&c.t.datagrams, 1)
+		}
+	}
+}
+
+func TestHandshakeResumption(t *testing.T) {
+	serverConf, err := NewServerTLSConfig(TLSConfig{})
+	assert.NoError(t, err)
+	clientConf, err := NewClientTLSConfig(TLSConfig{})
+	assert.NoError(t, err)
+	// Delay the server's reply, so that the client sends the datagram before
+	// the handshake completes.
+	serverConf.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		time.Sleep(50 * time.Millisecond)
+		return nil, nil
+	}
+	quicConf := &quic.Config{EnableDatagrams: true}
+
+	listener, err := quic.ListenAddrEarly("127.0.0.1:0", serverConf, quicConf)
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			session, err := listener.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				msg, err := session.ReceiveMessage()
+				if err != nil {
+					return
+				}
+				_ = session.SendMessage(msg, nil, nil)
+			}()
+		}
+	}()
+
+	// Without an address validation token, the receiver sends a Retry, which
+	// drops the 0-RTT datagram.
+	tokens := quic.NewLRUTokenStore(1, 1)
+	for _, expected := range []string{"full", "0-RTT"} {
+		tracer := &earlyDatagramTracer{RTTTracer: NewTracer()}
+		session, err := quic.DialAddrEarly(listener.Addr().String(), clientConf, &quic.Config{EnableDatagrams: true, TokenStore: tokens, Tracer: tracer})
+		assert.NoError(t, err)
+		// Session tickets remember that the receiver accepts datagrams, so
+		// media is sent in 0-RTT packets.
+		assert.Equal(t, expected == "0-RTT", tracer.EarlyDatagrams())
+		assert.NoError(t, session.SendMessage([]byte("media"), nil, nil))
+		assert.NoError(t, WaitHandshake(session, time.Now()))
+		assert.Equal(t, expected, handshakeType(session.ConnectionState()))
+
+		// The session ticket is received after the handshake, wait for the
+		// echo before closing.
+		msg, err := session.ReceiveMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("media"), msg)
+		assert.NoError(t, session.CloseWithError(0, "eos"))
+		if expected == "0-RTT" {
+			assert.Equal(t, int32(1), atomic.LoadInt32(&tracer.datagrams))
+		}
+	}
+}
@@ -2,11 +2,13 @@ package rtc
 
 import (
 	"io"
+	"time"
 
 	"github.com/pion/interceptor"
 	"github.com/pion/interceptor/pkg/cc"
 	"github.com/pion/interceptor/pkg/gcc"
 	"github.com/pion/interceptor/pkg/packetdump"
+	"github.com/pion/interceptor/pkg/report"
 	"github.com/pion/interceptor/pkg/twcc"
 	"github.com/pion/interceptor/scream/pkg/scream"
 )
@@ -16,6 +18,9 @@ func registerRTPSenderDumper(r *interceptor.Registry, rtp, rtcp io.Writer) error
 	rtpDumperInterceptor, err := packetdump.NewSenderInterceptor(
 		packetdump.RTPFormatter(rf.rtpFormat),
 		packetdump.RTPWriter(rtp),
+		// outgoing RTCP, i.e., sender reports
+		packetdump.RTCPFormatter(rtcpFormat),
+		packetdump.RTCPWriter(rtcp),
 	)
 	if err != nil {
 		return err
@@ -46,6 +51,9 @@ func registerRTPReceiverDumper(r *interceptor.Registry, rtp, rtcp io.Writer) err
 	rtpDumperInterceptor, err := packetdump.NewReceiverInterceptor(
 		packetdump.RTPFormatter(rf.rtpFormat),
 		packetdump.RTPWriter(rtp),
+		// incoming RTCP, i.e., sender reports
+		packetdump.RTCPFormatter(rtcpFormat),
+		packetdump.RTCPWriter(rtcp),
 	)
 	if err != nil {
 		return err
@@ -64,6 +72,65 @@ func registerTWCC(r *interceptor.Registry) error {
 	return nil
 }
 
+func registerREMB(r *interceptor.Registry) error {
+	r.Add(&rembReceiverFactory{})
+	return nil
+}
+
+func registerKeyframeRequests(r *interceptor.Registry, request KeyframeRequest, delay time.Duration) error {
+	if delay == 0 {
+		delay = DefaultKeyframeRequestDelay
+	}
+	r.Add(&keyframeRequesterFactory{
+		request: request,
+		delay:   delay,
+	})
+	return nil
+}
+
+func registerNACK(r *interceptor.Registry) error {
+	r.Add(&nackGeneratorFactory{})
+	return nil
+}
+
+func registerRTX(r *interceptor.Registry, m Metricer, playoutDelay time.Duration) error {
+	if playoutDelay == 0 {
+		playoutDelay = DefaultRTXPlayoutDelay
+	}
+	r.Add(&rtxResponderFactory{
+		m:            m,
+		playoutDelay: playoutDelay,
+	})
+	return nil
+}
+
+func registerFEC(r *interceptor.Registry, m Metricer, overhead float64, onOverhead func(float64)) error {
+	r.Add(&fecEncoderFactory{
+		m:          m,
+		overhead:   overhead,
+		onOverhead: onOverhead,
+	})
+	return nil
+}
+
+func registerSenderReports(r *interceptor.Registry) error {
+	sr, err := report.NewSenderInterceptor()
+	if err != nil {
+		return err
+	}
+	r.Add(sr)
+	return nil
+}
+
+func registerReceiverReports(r *interceptor.Registry) error {
+	rr, err := report.NewReceiverInterceptor()
+	if err != nil {
+		return err
+	}
+	r.Add(rr)
+	return nil
+}
+
 func registerTWCCHeaderExtension(r *interceptor.Registry) error {
 	headerExtension, err := twcc.NewHeaderExtensionInterceptor()
 	if err != nil {
@@ -76,37 +143,47 @@ func registerTWCCHeaderExtension(r *interceptor.Registry) error {
 const MIN_BITRATE = 2_000_000
 const MAX_BITRATE = 25_000_000
 
-func registerGCC(r *interceptor.Registry, initialBitrate uint, cb cc.NewPeerConnectionCallback) error {
+func registerGCC(r *interceptor.Registry, c RateControllerConfig, cb cc.NewPeerConnectionCallback) error {
 	fx := func() (cc.BandwidthEstimator, error) {
-		return gcc.NewSendSideBWE(gcc.SendSideBWEInitialBitrate(int(initialBitrate)), gcc.SendSideBWEPacer(gcc.NewLeakyBucketPacer(int(initialBitrate))),
-			gcc.SendSideBWEMinBitrate(MIN_BITRATE), gcc.SendSideBWEMaxBitrate(MAX_BITRATE))
+		return gcc.NewSendSideBWE(gcc.SendSideBWEInitialBitrate(int(c.InitialBitrate)), gcc.SendSideBWEPacer(gcc.NewLeakyBucketPacer(int(c.InitialBitrate))),
+			gcc.SendSideBWEMinBitrate(int(c.MinBitrate)), gcc.SendSideBWEMaxBitrate(int(c.MaxBitrate)))
 	}
 	gccFactory, err := cc.NewInterceptor(fx)
 	if err != nil {
 		return err
 	}
 	gccFactory.OnNewPeerConnection(cb)
-	r.Add(gccFactory)
+	r.Add(&feedbackTranslatorFactory{factory: gccFactory, to: twccFeedback})
 	return nil
 }
 
-func registerRFC8888(r *interceptor.Registry) error {
-	var rx *scream.ReceiverInterceptorFactory
-	rx, err := scream.NewReceiverInterceptor()
-	if err != nil {
-		return err
+func registerNADA(r *interceptor.Registry, c RateControllerConfig, cb func(*nadaInterceptor)) error {
+	r.Add(&nadaInterceptorFactory{
+		config: c,
+		onNew:  cb,
+	})
+	return nil
+}
+
+func registerRFC8888(r *interceptor.Registry, format CCFBFormat, interval time.Duration, dump io.Writer) error {
+	if interval == 0 {
+		interval = DefaultCCFBInterval
 	}
-	r.Add(rx)
+	r.Add(&ccfbReceiverFactory{
+		format:   format,
+		interval: interval,
+		dump:     dump,
+	})
 	return nil
 }
 
-func registerSCReAM(r *interceptor.Registry, initialBitrate uint, cb scream.NewPeerConnectionCallback) error {
+func registerSCReAM(r *interceptor.Registry, c RateControllerConfig, cb scream.NewPeerConnectionCallback) error {
 	var tx *scream.SenderInterceptorFactory
-	tx, err := scream.NewSenderInterceptor(scream.InitialBitrate(float64(initialBitrate)), scream.MinBitrate(MIN_BITRATE), scream.MaxBitrate(MAX_BITRATE))
+	tx, err := scream.NewSenderInterceptor(scream.InitialBitrate(float64(c.InitialBitrate)), scream.MinBitrate(float64(c.MinBitrate)), scream.MaxBitrate(float64(c.MaxBitrate)))
 	if err != nil {
 		return err
 	}
 	tx.OnNewPeerConnection(cb)
-	r.Add(tx)
+	r.Add(&feedbackTranslatorFactory{factory: tx, to: ccfbFeedback})
 	return nil
 }
@@ -0,0 +1,251 @@
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+)
+
+// KeyframeRequest is the RTCP message a receiver sends to ask for a keyframe.
+type KeyframeRequest int
+
+const (
+	NoKeyframeRequest KeyframeRequest = iota
+	// KeyframeRequestPLI sends picture loss indications, RFC 4585.
+	KeyframeRequestPLI
+	// KeyframeRequestFIR sends full intra requests, RFC 5104.
+	KeyframeRequestFIR
+)
+
+func ParseKeyframeRequest(s string) (KeyframeRequest, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return NoKeyframeRequest, nil
+	case "pli":
+		return KeyframeRequestPLI, nil
+	case "fir":
+		return KeyframeRequestFIR, nil
+	}
+	return 0, fmt.Errorf("unknown keyframe request: %v", s)
+}
+
+func (k KeyframeRequest) String() string {
+	switch k {
+	case NoKeyframeRequest:
+		return "none"
+	case KeyframeRequestPLI:
+		return "pli"
+	case KeyframeRequestFIR:
+		return "fir"
+	}
+	return fmt.Sprintf("KeyframeRequest(%d)", int(k))
+}
+
+const (
+	// DefaultKeyframeRequestDelay is how long a missing packet may be late
+	// before a keyframe is requested, if
+	// ReceiverConfig.KeyframeRequestDelay is zero.
+	DefaultKeyframeRequestDelay = 50 * time.Millisecond
+	// minKeyframeInterval limits both how often a receiver requests
+	// keyframes of a stream and how often a sender forces them.
+	minKeyframeInterval = 500 * time.Millisecond
+	// keyframeCheckInterval is how often the receiver checks for packets
+	// which did not arrive in time.
+	keyframeCheckInterval = 10 * time.Millisecond
+	// maxKeyframeGap limits the missing packets remembered for a single
+	// gap.
+	maxKeyframeGap = 1000
+)
+
+// ErrKeyframeUnsupported is returned by MediaSources which can't force
+// keyframes.
+var ErrKeyframeUnsupported = errors.New("media source can't force keyframes")
+
+type keyframeStream struct {
+	seq     unwrapper
+	started bool
+	highest int64
+	// missing holds the time each missing packet was detected.
+	missing     map[int64]time.Time
+	lastRequest time.Time
+	firSeq      uint8
+}
+
+// add records the arrival of seq, the packets it skips are missing.
+func (s *keyframeStream) add(seq uint16, now time.Time) {
+	n := s.seq.unwrap(seq)
+	if !s.started {
+		s.started, s.highest = true, n
+		return
+	}
+	if n <= s.highest {
+		delete(s.missing, n)
+		return
+	}
+	for m := n - 1; m > s.highest && m >= n-maxKeyframeGap; m-- {
+		s.missing[m] = now
+	}
+	s.highest = n
+}
+
+// due reports whether a packet is missing for longer than delay and the last
+// request is long enough ago.
+func (s *keyframeStream) due(now time.Time, delay time.Duration) bool {
+	if now.Sub(s.lastRequest) < minKeyframeInterval {
+		return false
+	}
+	for _, at := range s.missing {
+		if now.Sub(at) >= delay {
+			return true
+		}
+	}
+	return false
+}
+
+type keyframeRequesterFactory struct {
+	request KeyframeRequest
+	delay   time.Duration
+}
+
+func (f *keyframeRequesterFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return &keyframeRequester{
+		request: f.request,
+		delay:   f.delay,
+		streams: map[uint32]*keyframeStream{},
+		close:   make(chan struct{}),
+	}, nil
+}
+
+// keyframeRequester asks the sender for a keyframe when a packet of a stream
+// is missing for longer than delay, since the decoder can't continue without
+// it.
+type keyframeRequester struct {
+	interceptor.NoOp
+	request KeyframeRequest
+	delay   time.Duration
+
+	lock    sync.Mutex
+	streams map[uint32]*keyframeStream
+
+	wg        sync.WaitGroup
+	close     chan struct{}
+	closeOnce sync.Once
+}
+
+func (r *keyframeRequester) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	r.wg.Add(1)
+	go r.loop(writer)
+	return writer
+}
+
+func (r *keyframeRequester) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	r.lock.Lock()
+	r.streams[info.SSRC] = &keyframeStream{
+		missing: map[int64]time.Time{},
+	}
+	r.lock.Unlock()
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		header, err := attr.GetRTPHeader(b[:n])
+		if err != nil {
+			return 0, nil, err
+		}
+		r.lock.Lock()
+		if s, ok := r.streams[info.SSRC]; ok {
+			s.add(header.SequenceNumber, time.Now())
+		}
+		r.lock.Unlock()
+		return n, attr, nil
+	})
+}
+
+func (r *keyframeRequester) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.streams, info.SSRC)
+}
+
+func (r *keyframeRequester) loop(writer interceptor.RTCPWriter) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(keyframeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.close:
+			return
+		case now := <-ticker.C:
+			for _, pkt := range r.requests(now) {
+				if _, err := writer.Write([]rtcp.Packet{pkt}, nil); err != nil {
+					log.Printf("failed to send keyframe request: %v\n", err)
+				}
+			}
+		}
+	}
+}
+
+// requests returns a keyframe request for every stream which is due.
+func (r *keyframeRequester) requests(now time.Time) []rtcp.Packet {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var pkts []rtcp.Packet
+	for ssrc, s := range r.streams {
+		if !s.due(now, r.delay) {
+			continue
+		}
+		log.Printf("requesting keyframe for SSRC %v with %v, %v packets missing\n", ssrc, r.request, len(s.missing))
+		s.missing = map[int64]time.Time{}
+		s.lastRequest = now
+		if r.request == KeyframeRequestFIR {
+			pkts = append(pkts, &rtcp.FullIntraRequest{
+				MediaSSRC: ssrc,
+				FIR:       []rtcp.FIREntry{{SSRC: ssrc, SequenceNumber: s.firSeq}},
+			})
+			s.firSeq++
+			continue
+		}
+		pkts = append(pkts, &rtcp.PictureLossIndication{MediaSSRC: ssrc})
+	}
+	return pkts
+}
+
+func (r *keyframeRequester) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.close)
+	})
+	r.wg.Wait()
+	return nil
+}
+
+// keyframeRequests returns the SSRCs of the streams pkts ask keyframes for.
+// FIRs which repeat the sequence number of the last FIR of the stream in
+// firSeqs are retransmissions and ignored, see RFC 5104, Section 4.3.1.
+func keyframeRequests(pkts []rtcp.Packet, firSeqs map[uint32]uint8) []uint32 {
+	var ssrcs []uint32
+	for _, pkt := range pkts {
+		switch p := pkt.(type) {
+		case *rtcp.PictureLossIndication:
+			ssrcs = append(ssrcs, p.MediaSSRC)
+		case *rtcp.FullIntraRequest:
+			for _, e := range p.FIR {
+				if last, ok := firSeqs[e.SSRC]; ok && last == e.SequenceNumber {
+					continue
+				}
+				firSeqs[e.SSRC] = e.SequenceNumber
+				ssrcs = append(ssrcs, e.SSRC)
+			}
+		}
+	}
+	return ssrcs
+}
@@ -0,0 +1,71 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyframeRequester(t *testing.T) {
+	i, err := (&keyframeRequesterFactory{request: KeyframeRequestFIR, delay: 20 * time.Millisecond}).NewInterceptor("")
+	assert.NoError(t, err)
+	r := i.(*keyframeRequester)
+	reader := r.BindRemoteStream(&interceptor.StreamInfo{SSRC: 1}, interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return len(b), a, nil
+	}))
+	receive := func(seq uint16) {
+		buf, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 1, SequenceNumber: seq}}).Marshal()
+		assert.NoError(t, err)
+		_, _, err = reader.Read(buf, nil)
+		assert.NoError(t, err)
+	}
+
+	// Reordered packets don't cause requests.
+	receive(65534)
+	receive(0)
+	receive(65535)
+	assert.Empty(t, r.requests(time.Now().Add(time.Second)))
+
+	// Packets missing for longer than the delay do.
+	receive(2)
+	now := time.Now()
+	assert.Empty(t, r.requests(now))
+	assert.Equal(t, []rtcp.Packet{&rtcp.FullIntraRequest{
+		MediaSSRC: 1,
+		FIR:       []rtcp.FIREntry{{SSRC: 1, SequenceNumber: 0}},
+	}}, r.requests(now.Add(20*time.Millisecond)))
+
+	// Requests are rate limited.
+	receive(4)
+	assert.Empty(t, r.requests(now.Add(100*time.Millisecond)))
+	pkts := r.requests(now.Add(minKeyframeInterval + 20*time.Millisecond))
+	assert.Len(t, pkts, 1)
+	assert.Equal(t, uint8(1), pkts[0].(*rtcp.FullIntraRequest).FIR[0].SequenceNumber)
+}
+
+func TestForceKeyframes(t *testing.T) {
+	src := &bitrateSource{}
+	s := &Sender{
+		flows:   map[uint64]*sendFlow{0: {media: src, info: &interceptor.StreamInfo{SSRC: 1}}},
+		firSeqs: map[uint32]uint8{},
+	}
+	fir := func(seq uint8) []rtcp.Packet {
+		return []rtcp.Packet{&rtcp.FullIntraRequest{FIR: []rtcp.FIREntry{{SSRC: 1, SequenceNumber: seq}}}}
+	}
+
+	s.forceKeyframes(fir(0))
+	assert.Equal(t, 1, src.keyframes)
+
+	// Retransmitted FIRs are ignored, new requests are rate limited.
+	s.flows[0].lastKeyframe = time.Time{}
+	s.forceKeyframes(fir(0))
+	assert.Equal(t, 1, src.keyframes)
+	s.forceKeyframes(fir(1))
+	assert.Equal(t, 2, src.keyframes)
+	s.forceKeyframes([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: 1}})
+	assert.Equal(t, 2, src.keyframes)
+}
@@ -0,0 +1,467 @@
+package rtc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// Every message a MultipathTransport sends starts with the 4 byte ID of the
+// transport, which lets the receiver merge its paths, see multipathGroups.
+// Messages from the receiver are not prefixed.
+const multipathHeaderLen = 4
+
+// PathScheduler selects the paths of a MultipathTransport a message is sent
+// on.
+type PathScheduler int
+
+const (
+	// RedundantScheduler sends every message on all paths.
+	RedundantScheduler PathScheduler = iota
+	// LowestRTTScheduler sends every message on the path with the lowest
+	// smoothed RTT.
+	LowestRTTScheduler
+	// WeightedScheduler splits messages across paths in proportion to the
+	// weights of the paths.
+	WeightedScheduler
+)
+
+func ParsePathScheduler(s string) (PathScheduler, error) {
+	switch s {
+	case "redundant":
+		return RedundantScheduler, nil
+	case "lowest-rtt":
+		return LowestRTTScheduler, nil
+	case "weighted":
+		return WeightedScheduler, nil
+	}
+	return 0, fmt.Errorf("unknown path scheduler: %v", s)
+}
+
+func (p PathScheduler) String() string {
+	switch p {
+	case RedundantScheduler:
+		return "redundant"
+	case LowestRTTScheduler:
+		return "lowest-rtt"
+	case WeightedScheduler:
+		return "weighted"
+	}
+	return fmt.Sprintf("PathScheduler(%d)", int(p))
+}
+
+// PathDialer dials one path of a MultipathTransport.
+type PathDialer struct {
+	// Name identifies the path in logs and in the path dump, usually the
+	// local interface or address of the path.
+	Name string
+	Dial Dialer
+	// Weight of the path for WeightedScheduler. Zero counts as one.
+	Weight uint
+}
+
+type MultipathConfig struct {
+	Scheduler PathScheduler
+	// Dump receives statistics of every path every 200ms.
+	Dump io.Writer
+}
+
+var errNoPath = errors.New("no path connected")
+
+// MultipathTransport sends messages over several Transports, usually bound to
+// different local interfaces. Paths which break are redialed with backoff
+// while the others keep going. Messages sent while no path is connected are
+// dropped. RTCP received on a path is passed to the transport of the path, so
+// the metrics of every path are measured on their own.
+type MultipathTransport struct {
+	id        uint32
+	scheduler PathScheduler
+	paths     []*path
+	in        chan []byte
+
+	// protects the scheduler state of the paths
+	lock sync.Mutex
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+type path struct {
+	name   string
+	dial   Dialer
+	weight int
+
+	// current is the state of the smooth weighted round robin of
+	// WeightedScheduler.
+	current int
+
+	lock      sync.Mutex
+	transport Transport
+	packets   uint64
+	bytes     uint64
+	failures  uint64
+}
+
+// NewMultipathTransport dials all paths and fails if none of them can be
+// connected.
+func NewMultipathTransport(dialers []PathDialer, c MultipathConfig) (*MultipathTransport, error) {
+	if len(dialers) == 0 {
+		return nil, errors.New("multipath transport needs at least one path")
+	}
+	var id [multipathHeaderLen]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &MultipathTransport{
+		id:        binary.BigEndian.Uint32(id[:]),
+		scheduler: c.Scheduler,
+		in:        make(chan []byte, 1000),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	connected := 0
+	for _, d := range dialers {
+		p := &path{
+			name:   d.Name,
+			dial:   d.Dial,
+			weight: int(d.Weight),
+		}
+		if p.weight == 0 {
+			p.weight = 1
+		}
+		transport, err := p.dial()
+		if err != nil {
+			log.Printf("failed to connect path %v: %v\n", p.name, err)
+		} else {
+			log.Printf("path %v connected\n", p.name)
+			p.transport = transport
+			connected++
+		}
+		t.paths = append(t.paths, p)
+	}
+	if connected == 0 {
+		cancel()
+		return nil, errNoPath
+	}
+	for _, p := range t.paths {
+		t.wg.Add(1)
+		go t.runPath(p)
+	}
+	if c.Dump != nil {
+		t.wg.Add(1)
+		go t.dump(c.Dump)
+	}
+	return t, nil
+}
+
+// runPath forwards messages received on p and redials p when it breaks.
+func (t *MultipathTransport) runPath(p *path) {
+	defer t.wg.Done()
+	backoff := reconnectMinBackoff
+	for {
+		transport := p.getTransport()
+		if transport == nil {
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			var err error
+			transport, err = p.dial()
+			if err != nil {
+				log.Printf("failed to reconnect path %v: %v, retrying in %v\n", p.name, err, backoff)
+				if backoff *= 2; backoff > reconnectMaxBackoff {
+					backoff = reconnectMaxBackoff
+				}
+				continue
+			}
+			if !p.setTransport(t.ctx, transport) {
+				transport.CloseWithError(0, "eos")
+				return
+			}
+			log.Printf("path %v reconnected\n", p.name)
+			backoff = reconnectMinBackoff
+		}
+		for {
+			msg, err := transport.ReceiveMessage()
+			if err != nil {
+				select {
+				case <-t.ctx.Done():
+					return
+				default:
+				}
+				log.Printf("path %v failed: %v\n", p.name, err)
+				p.fail(transport)
+				break
+			}
+			handlePathRTCP(transport, msg)
+			select {
+			case t.in <- msg:
+			case <-t.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// handlePathRTCP passes the RTCP received on a path to its transport, so that
+// transports which derive their metrics from RTCP measure the RTT of their
+// path, see rtcpHandler.
+func handlePathRTCP(transport Transport, msg []byte) {
+	h, ok := transport.(rtcpHandler)
+	if !ok {
+		return
+	}
+	pkts, err := rtcp.Unmarshal(msg)
+	if err != nil {
+		return
+	}
+	h.HandleRTCP(pkts)
+}
+
+func (p *path) getTransport() Transport {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.transport
+}
+
+// setTransport returns false if ctx is done, so that the transport isn't leaked
+// after closing.
+func (p *path) setTransport(ctx context.Context, transport Transport) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if ctx.Err() != nil {
+		return false
+	}
+	p.transport = transport
+	return true
+}
+
+// fail closes transport if it is still the transport of p.
+func (p *path) fail(transport Transport) {
+	p.lock.Lock()
+	if p.transport != transport {
+		p.lock.Unlock()
+		return
+	}
+	p.transport = nil
+	p.failures++
+	p.lock.Unlock()
+	if err := transport.CloseWithError(0, "path failed"); err != nil {
+		log.Printf("failed to close path %v: %v\n", p.name, err)
+	}
+}
+
+func (p *path) send(msg []byte, sentCB func(error), ackLossCB func(bool)) {
+	transport := p.getTransport()
+	if transport == nil {
+		return
+	}
+	if err := transport.SendMessage(msg, sentCB, ackLossCB); err != nil {
+		log.Printf("failed to send on path %v: %v\n", p.name, err)
+		p.fail(transport)
+		return
+	}
+	p.lock.Lock()
+	p.packets++
+	p.bytes += uint64(len(msg))
+	p.lock.Unlock()
+}
+
+// SendMessage sends msg on the paths selected by the scheduler. The callbacks
+// are only passed to the first of them.
+func (t *MultipathTransport) SendMessage(msg []byte, sentCB func(error), ackLossCB func(bool)) error {
+	select {
+	case <-t.ctx.Done():
+		return errSessionClosed
+	default:
+	}
+	buf := make([]byte, multipathHeaderLen+len(msg))
+	binary.BigEndian.PutUint32(buf, t.id)
+	copy(buf[multipathHeaderLen:], msg)
+	for i, p := range t.schedule() {
+		if i == 0 {
+			p.send(buf, sentCB, ackLossCB)
+		} else {
+			p.send(buf, nil, nil)
+		}
+	}
+	return nil
+}
+
+func (t *MultipathTransport) schedule() []*path {
+	connected := make([]*path, 0, len(t.paths))
+	for _, p := range t.paths {
+		if p.getTransport() != nil {
+			connected = append(connected, p)
+		}
+	}
+	if len(connected) <= 1 {
+		return connected
+	}
+	switch t.scheduler {
+	case LowestRTTScheduler:
+		return []*path{lowestRTT(connected)}
+	case WeightedScheduler:
+		return []*path{t.weighted(connected)}
+	}
+	return connected
+}
+
+// lowestRTT returns the path with the lowest smoothed RTT. Paths without RTT
+// samples are only chosen if no path has one.
+func lowestRTT(paths []*path) *path {
+	var best *path
+	var bestRTT time.Duration
+	for _, p := range paths {
+		transport := p.getTransport()
+		if transport == nil {
+			continue
+		}
+		rtt := transport.Metrics().SmoothedRTT
+		if best == nil || (rtt > 0 && (bestRTT == 0 || rtt < bestRTT)) {
+			best, bestRTT = p, rtt
+		}
+	}
+	return best
+}
+
+// weighted selects paths by smooth weighted round robin.
+func (t *MultipathTransport) weighted(paths []*path) *path {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var best *path
+	total := 0
+	for _, p := range paths {
+		p.current += p.weight
+		total += p.weight
+		if best == nil || p.current > best.current {
+			best = p
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// ReceiveMessage returns the next message received on any path.
+func (t *MultipathTransport) ReceiveMessage() ([]byte, error) {
+	select {
+	case msg := <-t.in:
+		return msg, nil
+	case <-t.ctx.Done():
+		return nil, errSessionClosed
+	}
+}
+
+func (t *MultipathTransport) CloseWithError(code int, msg string) error {
+	var err error
+	t.closeOnce.Do(func() {
+		t.cancel()
+		for _, p := range t.paths {
+			p.lock.Lock()
+			transport := p.transport
+			p.transport = nil
+			p.lock.Unlock()
+			if transport == nil {
+				continue
+			}
+			if cerr := transport.CloseWithError(code, msg); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+		t.wg.Wait()
+	})
+	return err
+}
+
+// Metrics returns the metrics of the path with the lowest RTT.
+func (t *MultipathTransport) Metrics() RTTStats {
+	p := lowestRTT(t.paths)
+	if p == nil {
+		return RTTStats{}
+	}
+	transport := p.getTransport()
+	if transport == nil {
+		return RTTStats{}
+	}
+	return transport.Metrics()
+}
+
+func (t *MultipathTransport) dump(w io.Writer) {
+	defer t.wg.Done()
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, p := range t.paths {
+				p.lock.Lock()
+				transport := p.transport
+				packets, bytes, failures := p.packets, p.bytes, p.failures
+				p.lock.Unlock()
+				var stats RTTStats
+				if transport != nil {
+					stats = transport.Metrics()
+				}
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+					now.Format(time.RFC3339Nano),
+					p.name,
+					transport != nil,
+					packets,
+					bytes,
+					failures,
+					stats.SmoothedRTT.Milliseconds(),
+					stats.MinRTT.Milliseconds(),
+					stats.Lost,
+				)
+			}
+		}
+	}
+}
+
+// LocalIP resolves the name of a local interface to its first IPv4 address,
+// or its first address if it has none. Addresses are returned as they are.
+func LocalIP(name string) (net.IP, error) {
+	if ip := net.ParseIP(name); ip != nil {
+		return ip, nil
+	}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var first net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			return ip4, nil
+		}
+		if first == nil {
+			first = ipNet.IP
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("interface %v has no address", name)
+	}
+	return first, nil
+}
@@ -0,0 +1,290 @@
+package rtc
+
+import (
+	"context"
+	"encoding/binary"
+	"log"
+	"sync"
+)
+
+// multipathGroups merges the paths of MultipathTransports. Each path is read
+// by a Receiver which forwards its messages to a multipathSession shared by
+// all paths of the same sender, on which the Receiver of the group runs.
+type multipathGroups struct {
+	lock        sync.Mutex
+	groups      map[uint32]*multipathSession
+	newReceiver ReceiverFactory
+}
+
+func newMultipathGroups(newReceiver ReceiverFactory) *multipathGroups {
+	return &multipathGroups{
+		groups:      map[uint32]*multipathSession{},
+		newReceiver: newReceiver,
+	}
+}
+
+// forward reads messages from path and forwards them to the group of the
+// MultipathTransport which sent them.
+func (g *multipathGroups) forward(ctx context.Context, path Transport, sinkFactory MediaSinkFactory) error {
+	var group *multipathSession
+	defer func() {
+		if group != nil {
+			group.removePath(path)
+		}
+	}()
+	for {
+		msg, err := path.ReceiveMessage()
+		if err != nil {
+			return err
+		}
+		if len(msg) < multipathHeaderLen {
+			log.Println("got multipath message without header, dropping message")
+			continue
+		}
+		id := binary.BigEndian.Uint32(msg)
+		if group == nil {
+			group, err = g.join(ctx, id, path, sinkFactory)
+			if err != nil {
+				return err
+			}
+		} else if group.id != id {
+			log.Printf("got message of multipath group %x on path of group %x, dropping message\n", id, group.id)
+			continue
+		}
+		group.deliver(path, msg[multipathHeaderLen:])
+	}
+}
+
+// join adds path to the group with id and starts a Receiver for the group if
+// it is new.
+func (g *multipathGroups) join(ctx context.Context, id uint32, path Transport, sinkFactory MediaSinkFactory) (*multipathSession, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if group, ok := g.groups[id]; ok && group.addPath(path) {
+		log.Printf("new path of multipath group %x\n", id)
+		return group, nil
+	}
+	group := &multipathSession{
+		id:    id,
+		paths: []Transport{path},
+		in:    make(chan []byte, 1000),
+		done:  make(chan struct{}),
+	}
+	group.onClose = func() {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		if g.groups[id] == group {
+			delete(g.groups, id)
+		}
+	}
+	receiver, err := g.newReceiver(group, sinkFactory)
+	if err != nil {
+		return nil, err
+	}
+	receiver.dedup = newDeduplicator()
+	g.groups[id] = group
+	log.Printf("new multipath group %x\n", id)
+
+	go func() {
+		defer receiver.Close()
+		err := receiver.run(ctx)
+		log.Printf("multipath group %x closed: %v, dropped %v duplicates\n", id, err, receiver.duplicates())
+	}()
+	return group, nil
+}
+
+// multipathSession is the Transport of the Receiver of a multipath group. It
+// sends on the path which most recently received a message.
+type multipathSession struct {
+	id uint32
+	in chan []byte
+
+	lock  sync.Mutex
+	paths []Transport
+	last  Transport
+
+	closeOnce sync.Once
+	done      chan struct{}
+	onClose   func()
+}
+
+// addPath returns false if the session is closed already.
+func (s *multipathSession) addPath(path Transport) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+	s.paths = append(s.paths, path)
+	return true
+}
+
+// removePath closes the session when its last path is removed.
+func (s *multipathSession) removePath(path Transport) {
+	s.lock.Lock()
+	for i, p := range s.paths {
+		if p == path {
+			s.paths = append(s.paths[:i], s.paths[i+1:]...)
+			break
+		}
+	}
+	if s.last == path {
+		s.last = nil
+	}
+	empty := len(s.paths) == 0
+	s.lock.Unlock()
+	if empty {
+		s.close()
+	}
+}
+
+func (s *multipathSession) deliver(path Transport, msg []byte) {
+	s.lock.Lock()
+	s.last = path
+	s.lock.Unlock()
+	select {
+	case s.in <- msg:
+	case <-s.done:
+	default:
+		log.Println("multipath buffer full, dropping message")
+	}
+}
+
+func (s *multipathSession) ReceiveMessage() ([]byte, error) {
+	select {
+	case msg := <-s.in:
+		return msg, nil
+	case <-s.done:
+		return nil, errSessionClosed
+	}
+}
+
+func (s *multipathSession) SendMessage(msg []byte, sentCB func(error), ackLossCB func(bool)) error {
+	s.lock.Lock()
+	paths := make([]Transport, 0, len(s.paths))
+	if s.last != nil {
+		paths = append(paths, s.last)
+	}
+	for _, p := range s.paths {
+		if p != s.last {
+			paths = append(paths, p)
+		}
+	}
+	s.lock.Unlock()
+
+	err := errNoPath
+	for _, p := range paths {
+		if err = p.SendMessage(msg, sentCB, ackLossCB); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (s *multipathSession) Metrics() RTTStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.last == nil {
+		return RTTStats{}
+	}
+	return s.last.Metrics()
+}
+
+// CloseWithError closes all paths of the session.
+func (s *multipathSession) CloseWithError(code int, msg string) error {
+	s.close()
+	s.lock.Lock()
+	paths := s.paths
+	s.paths = nil
+	s.lock.Unlock()
+	var err error
+	for _, p := range paths {
+		if cerr := p.CloseWithError(code, msg); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *multipathSession) close() {
+	s.closeOnce.Do(func() {
+		s.lock.Lock()
+		close(s.done)
+		s.lock.Unlock()
+		s.onClose()
+	})
+}
+
+// dedupWindow is how far, in sequence numbers, a duplicate may lag behind the
+// highest sequence number of its flow to be detected.
+const dedupWindow = 1024
+
+// deduplicator drops RTP packets which arrived on another path of a
+// multipath group before.
+type deduplicator struct {
+	flows      map[uint64]*seqWindow
+	duplicates uint64
+}
+
+func newDeduplicator() *deduplicator {
+	return &deduplicator{
+		flows: map[uint64]*seqWindow{},
+	}
+}
+
+// duplicate returns true if the packet with seq was seen on flow before.
+func (d *deduplicator) duplicate(flow uint64, seq uint16) bool {
+	w, ok := d.flows[flow]
+	if !ok {
+		w = &seqWindow{}
+		d.flows[flow] = w
+	}
+	if w.duplicate(seq) {
+		d.duplicates++
+		return true
+	}
+	return false
+}
+
+type seqWindow struct {
+	unwrapper unwrapper
+	init      bool
+	highest   int64
+	seen      [dedupWindow / 64]uint64
+}
+
+func (w *seqWindow) bit(seq int64) (int, uint64) {
+	i := (seq%dedupWindow + dedupWindow) % dedupWindow
+	return int(i / 64), 1 << uint(i%64)
+}
+
+func (w *seqWindow) duplicate(seq uint16) bool {
+	s := w.unwrapper.unwrap(seq)
+	if !w.init {
+		w.init = true
+		w.highest = s
+		i, b := w.bit(s)
+		w.seen[i] |= b
+		return false
+	}
+	if s > w.highest {
+		// Forget the sequence numbers which moved out of the window.
+		for n := w.highest + 1; n <= s && n <= w.highest+dedupWindow; n++ {
+			i, b := w.bit(n)
+			w.seen[i] &^= b
+		}
+		w.highest = s
+	} else if w.highest-s >= dedupWindow {
+		// Too old to tell, it has been played out anyway.
+		return true
+	}
+	i, b := w.bit(s)
+	if w.seen[i]&b != 0 {
+		return true
+	}
+	w.seen[i] |= b
+	return false
+}
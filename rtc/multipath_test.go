@@ -0,0 +1,189 @@
+package rtc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+type rttPipeTransport struct {
+	*pipeTransport
+	rtt time.Duration
+}
+
+func (t *rttPipeTransport) Metrics() RTTStats {
+	return RTTStats{SmoothedRTT: t.rtt}
+}
+
+type lockedSink struct {
+	lock    sync.Mutex
+	packets [][]byte
+}
+
+func (s *lockedSink) Write(b []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.packets = append(s.packets, append([]byte{}, b...))
+	return len(b), nil
+}
+
+func (s *lockedSink) Close() error {
+	return nil
+}
+
+func (s *lockedSink) len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.packets)
+}
+
+// newTestMultipathTransport returns a MultipathTransport on paths with the
+// given RTTs and weights, and the receiving ends of the paths.
+func newTestMultipathTransport(t *testing.T, scheduler PathScheduler, rtts []time.Duration, weights []uint) (*MultipathTransport, []*pipeTransport) {
+	var dialers []PathDialer
+	var remotes []*pipeTransport
+	for i, rtt := range rtts {
+		local, remote := newPipeTransports()
+		transport := &rttPipeTransport{pipeTransport: local, rtt: rtt}
+		remotes = append(remotes, remote)
+		dialers = append(dialers, PathDialer{
+			Name: string(rune('a' + i)),
+			Dial: func() (Transport, error) {
+				return transport, nil
+			},
+			Weight: weights[i],
+		})
+	}
+	mp, err := NewMultipathTransport(dialers, MultipathConfig{Scheduler: scheduler})
+	assert.NoError(t, err)
+	return mp, remotes
+}
+
+func TestMultipathSchedulers(t *testing.T) {
+	for _, tc := range []struct {
+		scheduler PathScheduler
+		expected  []int
+	}{
+		{RedundantScheduler, []int{100, 100}},
+		{LowestRTTScheduler, []int{0, 100}},
+		{WeightedScheduler, []int{25, 75}},
+	} {
+		mp, remotes := newTestMultipathTransport(t, tc.scheduler, []time.Duration{20 * time.Millisecond, 10 * time.Millisecond}, []uint{1, 3})
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, mp.SendMessage([]byte{byte(i)}, nil, nil))
+		}
+		for i, remote := range remotes {
+			assert.Equal(t, tc.expected[i], len(remote.in), tc.scheduler.String())
+		}
+		assert.NoError(t, mp.CloseWithError(0, "eos"))
+	}
+}
+
+type rtcpPipeTransport struct {
+	*pipeTransport
+	*RTCPTracker
+}
+
+func (t *rtcpPipeTransport) Metrics() RTTStats {
+	return t.RTCPTracker.Metrics()
+}
+
+func TestMultipathMeasuresRTTPerPath(t *testing.T) {
+	var dialers []PathDialer
+	var locals []*rtcpPipeTransport
+	var remotes []*pipeTransport
+	for _, name := range []string{"a", "b"} {
+		local, remote := newPipeTransports()
+		transport := &rtcpPipeTransport{pipeTransport: local, RTCPTracker: NewRTCPTracker()}
+		locals = append(locals, transport)
+		remotes = append(remotes, remote)
+		dialers = append(dialers, PathDialer{
+			Name: name,
+			Dial: func() (Transport, error) {
+				return transport, nil
+			},
+		})
+	}
+	mp, err := NewMultipathTransport(dialers, MultipathConfig{})
+	assert.NoError(t, err)
+	defer mp.CloseWithError(0, "eos")
+
+	rr, err := (&rtcp.ReceiverReport{
+		Reports: []rtcp.ReceptionReport{{
+			SSRC:             1,
+			LastSenderReport: ntpCompact(time.Now().Add(-100 * time.Millisecond)),
+		}},
+	}).Marshal()
+	assert.NoError(t, err)
+	assert.NoError(t, remotes[1].SendMessage(rr, nil, nil))
+	msg, err := mp.ReceiveMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, rr, msg)
+
+	assert.Zero(t, locals[0].Metrics().SmoothedRTT)
+	assert.InDelta(t, 100*time.Millisecond, locals[1].Metrics().SmoothedRTT, float64(20*time.Millisecond))
+}
+
+func TestMultipathReceiverDeduplicates(t *testing.T) {
+	mp, remotes := newTestMultipathTransport(t, RedundantScheduler, []time.Duration{0, 0}, []uint{0, 0})
+	defer mp.CloseWithError(0, "eos")
+
+	sink := &lockedSink{}
+	groups := newMultipathGroups(func(session Transport, sinkFactory MediaSinkFactory) (*Receiver, error) {
+		receiver, err := newReceiver(session, &interceptor.NoOp{})
+		if err != nil {
+			return nil, err
+		}
+		receiver.OnFlow(sinkFactory)
+		return receiver, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, remote := range remotes {
+		go groups.forward(ctx, remote, func(uint64) (MediaSink, error) {
+			return sink, nil
+		})
+	}
+
+	for i := 0; i < 10; i++ {
+		pkt := rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    96,
+				SequenceNumber: uint16(65530 + i),
+				SSRC:           1,
+			},
+			Payload: []byte{byte(i)},
+		}
+		buf, err := pkt.Marshal()
+		assert.NoError(t, err)
+		assert.NoError(t, mp.SendMessage(append(varintBytes(0), buf...), nil, nil))
+	}
+	assert.Eventually(t, func() bool {
+		return sink.len() == 10
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 10, sink.len())
+
+	groups.lock.Lock()
+	assert.Len(t, groups.groups, 1)
+	groups.lock.Unlock()
+}
+
+func TestSeqWindow(t *testing.T) {
+	w := &seqWindow{}
+	assert.False(t, w.duplicate(10))
+	assert.True(t, w.duplicate(10))
+	assert.False(t, w.duplicate(12))
+	assert.False(t, w.duplicate(11))
+	assert.True(t, w.duplicate(11))
+	assert.False(t, w.duplicate(10+dedupWindow))
+	assert.False(t, w.duplicate(12+dedupWindow))
+	assert.True(t, w.duplicate(12))
+}
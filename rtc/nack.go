@@ -0,0 +1,372 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// rtxPayloadType is the payload type of retransmissions, RFC 4588. Media uses
+// payload type 96.
+const rtxPayloadType = 97
+
+const (
+	// DefaultRTXPlayoutDelay is how long after sending a packet the receiver
+	// plays it out, if SenderConfig.RTXPlayoutDelay is zero. It matches the
+	// latency of the jitter buffer of the receiver.
+	DefaultRTXPlayoutDelay = 100 * time.Millisecond
+	// rtxHistorySize is the number of sent packets per stream kept for
+	// retransmissions.
+	rtxHistorySize = 1024
+	// nackCheckInterval is how often the receiver sends NACKs for missing
+	// packets.
+	nackCheckInterval = 10 * time.Millisecond
+	// nackRetryInterval is how long the receiver waits for a retransmission
+	// before it sends another NACK for the same packet.
+	nackRetryInterval = 50 * time.Millisecond
+	// maxNACKs is how often the receiver asks for the same packet.
+	maxNACKs = 3
+	// maxNACKSeqs limits the packets in a single NACK, so that it fits into
+	// a datagram.
+	maxNACKSeqs = 200
+)
+
+// rtxAttribute marks retransmitted packets in the attributes of the RTP writer
+// on the sender and of the RTP reader on the receiver.
+type rtxAttribute struct{}
+
+func isRTX(pkt []byte) bool {
+	return len(pkt) >= 2 && pkt[1]&0x7f == rtxPayloadType
+}
+
+// rtxSSRC returns the SSRC of the retransmissions of the stream ssrc. The
+// receiver identifies retransmissions by their payload type and flow.
+func rtxSSRC(ssrc uint32) uint32 {
+	return ssrc + 1
+}
+
+type sentPacket struct {
+	header  rtp.Header
+	payload []byte
+	sent    time.Time
+	// retransmitted is when the packet was last retransmitted.
+	retransmitted time.Time
+}
+
+type rtxStream struct {
+	// lock serializes retransmissions with the media packets.
+	lock    sync.Mutex
+	writer  interceptor.RTPWriter
+	history [rtxHistorySize]*sentPacket
+	seq     uint16
+}
+
+func (s *rtxStream) write(header *rtp.Header, payload []byte, a interceptor.Attributes) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.history[header.SequenceNumber%rtxHistorySize] = &sentPacket{
+		header:  header.Clone(),
+		payload: append([]byte(nil), payload...),
+		sent:    time.Now(),
+	}
+	return s.writer.Write(header, payload, a)
+}
+
+// retransmit sends packet seq again in an RTX packet, unless it is no longer
+// in the history, was retransmitted less than an RTT ago or would arrive after
+// its playout deadline. The original packet arrives after half the minimum
+// RTT and is played out playoutDelay later, the retransmission arrives after
+// half the latest RTT.
+func (s *rtxStream) retransmit(seq uint16, now time.Time, rtt RTTStats, playoutDelay time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p := s.history[seq%rtxHistorySize]
+	if p == nil || p.header.SequenceNumber != seq {
+		return
+	}
+	if now.Sub(p.retransmitted) < rtt.SmoothedRTT {
+		return
+	}
+	if now.Add(rtt.LatestRTT / 2).After(p.sent.Add(rtt.MinRTT/2 + playoutDelay)) {
+		return
+	}
+	header := p.header.Clone()
+	header.SSRC = rtxSSRC(header.SSRC)
+	header.PayloadType = rtxPayloadType
+	header.SequenceNumber = s.seq
+	header.Padding = false
+	// Retransmissions are not seen by the congestion controller, which
+	// would not know the transport-wide sequence number.
+	if header.Extension {
+		_ = header.DelExtension(transportCCExtensionID)
+		header.Extension = len(header.Extensions) > 0
+	}
+	payload := make([]byte, 2+len(p.payload))
+	binary.BigEndian.PutUint16(payload, seq)
+	copy(payload[2:], p.payload)
+
+	s.seq++
+	p.retransmitted = now
+	if _, err := s.writer.Write(&header, payload, interceptor.Attributes{rtxAttribute{}: true}); err != nil {
+		log.Printf("failed to retransmit packet %v of SSRC %v: %v\n", seq, p.header.SSRC, err)
+	}
+}
+
+type rtxResponderFactory struct {
+	m            Metricer
+	playoutDelay time.Duration
+}
+
+func (f *rtxResponderFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return &rtxResponder{
+		m:            f.m,
+		playoutDelay: f.playoutDelay,
+		streams:      map[uint32]*rtxStream{},
+	}, nil
+}
+
+// rtxResponder keeps a history of the packets sent on each stream and
+// retransmits the packets NACKs report missing, RFC 4585 and RFC 4588.
+type rtxResponder struct {
+	interceptor.NoOp
+	m            Metricer
+	playoutDelay time.Duration
+
+	lock    sync.Mutex
+	streams map[uint32]*rtxStream
+}
+
+func (r *rtxResponder) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	s := &rtxStream{writer: writer}
+	r.lock.Lock()
+	r.streams[info.SSRC] = s
+	r.lock.Unlock()
+	return interceptor.RTPWriterFunc(s.write)
+}
+
+func (r *rtxResponder) UnbindLocalStream(info *interceptor.StreamInfo) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.streams, info.SSRC)
+}
+
+func (r *rtxResponder) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		pkts, err := attr.GetRTCPPackets(b[:n])
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, pkt := range pkts {
+			if nack, ok := pkt.(*rtcp.TransportLayerNack); ok {
+				r.handleNACK(nack, time.Now())
+			}
+		}
+		return n, attr, nil
+	})
+}
+
+func (r *rtxResponder) handleNACK(nack *rtcp.TransportLayerNack, now time.Time) {
+	r.lock.Lock()
+	s, ok := r.streams[nack.MediaSSRC]
+	r.lock.Unlock()
+	if !ok {
+		return
+	}
+	rtt := r.m.Metrics()
+	for _, pair := range nack.Nacks {
+		for _, seq := range pair.PacketList() {
+			s.retransmit(seq, now, rtt, r.playoutDelay)
+		}
+	}
+}
+
+// restoreRTX returns the original packet of the RTX packet pkt of flow f,
+// RFC 4588, Section 4.
+func (f *receiveFlow) restoreRTX(pkt []byte) ([]byte, error) {
+	var p rtp.Packet
+	if err := p.Unmarshal(pkt); err != nil {
+		return nil, err
+	}
+	if len(p.Payload) < 2 {
+		return nil, errors.New("RTX packet without original sequence number")
+	}
+	p.SSRC = f.info.SSRC
+	p.PayloadType = f.payloadType
+	p.SequenceNumber = binary.BigEndian.Uint16(p.Payload)
+	p.Payload = p.Payload[2:]
+	p.Padding = false
+	return p.Marshal()
+}
+
+type missingPacket struct {
+	lastNACK time.Time
+	nacks    int
+}
+
+type nackStream struct {
+	seq     unwrapper
+	started bool
+	highest int64
+	missing map[int64]*missingPacket
+}
+
+// add records the arrival of seq, the packets it skips are missing.
+func (s *nackStream) add(seq uint16) {
+	n := s.seq.unwrap(seq)
+	if !s.started {
+		s.started, s.highest = true, n
+		return
+	}
+	if n <= s.highest {
+		delete(s.missing, n)
+		return
+	}
+	for m := n - 1; m > s.highest && m >= n-rtxHistorySize; m-- {
+		s.missing[m] = &missingPacket{}
+	}
+	s.highest = n
+}
+
+// due returns up to maxNACKSeqs sequence numbers of missing packets to NACK
+// now, oldest first, and forgets packets which were NACKed often enough.
+func (s *nackStream) due(now time.Time) []uint16 {
+	var ns []int64
+	for n, p := range s.missing {
+		if now.Sub(p.lastNACK) < nackRetryInterval {
+			continue
+		}
+		if p.nacks >= maxNACKs {
+			delete(s.missing, n)
+			continue
+		}
+		ns = append(ns, n)
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i] < ns[j] })
+	if len(ns) > maxNACKSeqs {
+		ns = ns[:maxNACKSeqs]
+	}
+	seqs := make([]uint16, len(ns))
+	for i, n := range ns {
+		s.missing[n].lastNACK = now
+		s.missing[n].nacks++
+		seqs[i] = uint16(n)
+	}
+	return seqs
+}
+
+type nackGeneratorFactory struct{}
+
+func (f *nackGeneratorFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return &nackGenerator{
+		streams: map[uint32]*nackStream{},
+		close:   make(chan struct{}),
+	}, nil
+}
+
+// nackGenerator sends NACKs for missing packets until they arrive or were
+// NACKed maxNACKs times.
+type nackGenerator struct {
+	interceptor.NoOp
+
+	lock    sync.Mutex
+	streams map[uint32]*nackStream
+
+	wg        sync.WaitGroup
+	close     chan struct{}
+	closeOnce sync.Once
+}
+
+func (g *nackGenerator) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	g.wg.Add(1)
+	go g.loop(writer)
+	return writer
+}
+
+func (g *nackGenerator) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	g.lock.Lock()
+	g.streams[info.SSRC] = &nackStream{
+		missing: map[int64]*missingPacket{},
+	}
+	g.lock.Unlock()
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		header, err := attr.GetRTPHeader(b[:n])
+		if err != nil {
+			return 0, nil, err
+		}
+		g.lock.Lock()
+		if s, ok := g.streams[info.SSRC]; ok {
+			s.add(header.SequenceNumber)
+		}
+		g.lock.Unlock()
+		return n, attr, nil
+	})
+}
+
+func (g *nackGenerator) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.streams, info.SSRC)
+}
+
+func (g *nackGenerator) loop(writer interceptor.RTCPWriter) {
+	defer g.wg.Done()
+	ticker := time.NewTicker(nackCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.close:
+			return
+		case now := <-ticker.C:
+			for _, pkt := range g.nacks(now) {
+				if _, err := writer.Write([]rtcp.Packet{pkt}, nil); err != nil {
+					log.Printf("failed to send NACK: %v\n", err)
+				}
+			}
+		}
+	}
+}
+
+// nacks returns a NACK for every stream with packets to NACK now.
+func (g *nackGenerator) nacks(now time.Time) []rtcp.Packet {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	var pkts []rtcp.Packet
+	for ssrc, s := range g.streams {
+		if seqs := s.due(now); len(seqs) > 0 {
+			pkts = append(pkts, &rtcp.TransportLayerNack{
+				MediaSSRC: ssrc,
+				Nacks:     rtcp.NackPairsFromSequenceNumbers(seqs),
+			})
+		}
+	}
+	return pkts
+}
+
+func (g *nackGenerator) Close() error {
+	g.closeOnce.Do(func() {
+		close(g.close)
+	})
+	g.wg.Wait()
+	return nil
+}
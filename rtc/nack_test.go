@@ -0,0 +1,105 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNACKGenerator(t *testing.T) {
+	i, err := (&nackGeneratorFactory{}).NewInterceptor("")
+	assert.NoError(t, err)
+	g := i.(*nackGenerator)
+	reader := g.BindRemoteStream(&interceptor.StreamInfo{SSRC: 1}, interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return len(b), a, nil
+	}))
+	receive := func(seq uint16) {
+		buf, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 1, SequenceNumber: seq}}).Marshal()
+		assert.NoError(t, err)
+		_, _, err = reader.Read(buf, nil)
+		assert.NoError(t, err)
+	}
+
+	receive(65533)
+	receive(1)
+	receive(65535)
+	now := time.Now()
+	assert.Equal(t, []rtcp.Packet{&rtcp.TransportLayerNack{
+		MediaSSRC: 1,
+		Nacks:     []rtcp.NackPair{{PacketID: 65534, LostPackets: 0b10}},
+	}}, g.nacks(now))
+
+	// Packets are NACKed again after nackRetryInterval until they arrive or
+	// were NACKed maxNACKs times.
+	assert.Empty(t, g.nacks(now.Add(nackRetryInterval/2)))
+	receive(0)
+	for n := 1; n < maxNACKs; n++ {
+		now = now.Add(nackRetryInterval)
+		assert.Equal(t, []rtcp.Packet{&rtcp.TransportLayerNack{
+			MediaSSRC: 1,
+			Nacks:     []rtcp.NackPair{{PacketID: 65534}},
+		}}, g.nacks(now))
+	}
+	assert.Empty(t, g.nacks(now.Add(nackRetryInterval)))
+}
+
+func TestRTXResponder(t *testing.T) {
+	m := &staticMetricer{MinRTT: 20 * time.Millisecond, SmoothedRTT: 40 * time.Millisecond, LatestRTT: 40 * time.Millisecond}
+	i, err := (&rtxResponderFactory{m: m, playoutDelay: 100 * time.Millisecond}).NewInterceptor("")
+	assert.NoError(t, err)
+	r := i.(*rtxResponder)
+	type written struct {
+		header  rtp.Header
+		payload []byte
+		rtx     bool
+	}
+	var writes []written
+	writer := r.BindLocalStream(&interceptor.StreamInfo{SSRC: 1}, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, a interceptor.Attributes) (int, error) {
+		rtx, _ := a.Get(rtxAttribute{}).(bool)
+		writes = append(writes, written{header.Clone(), append([]byte(nil), payload...), rtx})
+		return len(payload), nil
+	}))
+	header := rtp.Header{Version: 2, PayloadType: 96, SSRC: 1, SequenceNumber: 7, Timestamp: 90}
+	assert.NoError(t, header.SetExtension(transportCCExtensionID, []byte{0, 3}))
+	_, err = writer.Write(&header, []byte{1, 2, 3}, nil)
+	assert.NoError(t, err)
+
+	nack := &rtcp.TransportLayerNack{MediaSSRC: 1, Nacks: rtcp.NackPairsFromSequenceNumbers([]uint16{6, 7})}
+	now := time.Now()
+	r.handleNACK(nack, now)
+	assert.Len(t, writes, 2)
+	rtx := writes[1]
+	assert.True(t, rtx.rtx)
+	assert.Equal(t, uint8(rtxPayloadType), rtx.header.PayloadType)
+	assert.Equal(t, rtxSSRC(1), rtx.header.SSRC)
+	assert.Equal(t, uint32(90), rtx.header.Timestamp)
+	assert.False(t, rtx.header.Extension)
+	assert.Equal(t, []byte{0, 7, 1, 2, 3}, rtx.payload)
+
+	// The receiver restores the original packet.
+	buf, err := (&rtp.Packet{Header: rtx.header, Payload: rtx.payload}).Marshal()
+	assert.NoError(t, err)
+	flow := &receiveFlow{info: &interceptor.StreamInfo{SSRC: 1}, payloadType: 96}
+	buf, err = flow.restoreRTX(buf)
+	assert.NoError(t, err)
+	var restored rtp.Packet
+	assert.NoError(t, restored.Unmarshal(buf))
+	assert.Equal(t, uint16(7), restored.SequenceNumber)
+	assert.Equal(t, uint8(96), restored.PayloadType)
+	assert.Equal(t, uint32(1), restored.SSRC)
+	assert.Equal(t, []byte{1, 2, 3}, restored.Payload)
+
+	// Packets are retransmitted at most once per RTT and not after their
+	// playout deadline.
+	r.handleNACK(nack, now.Add(20*time.Millisecond))
+	assert.Len(t, writes, 2)
+	r.handleNACK(nack, now.Add(50*time.Millisecond))
+	assert.Len(t, writes, 3)
+	assert.Equal(t, uint16(1), writes[2].header.SequenceNumber)
+	r.handleNACK(nack, now.Add(100*time.Millisecond))
+	assert.Len(t, writes, 3)
+}
@@ -0,0 +1,386 @@
+package rtc
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+)
+
+// NADA parameters, see RFC 8698, Section 6.3.
+const (
+	nadaPrio     = 1.0
+	nadaXRef     = 10 * time.Millisecond
+	nadaKappa    = 0.5
+	nadaEta      = 2.0
+	nadaTau      = 500 * time.Millisecond
+	nadaDelta    = 100 * time.Millisecond
+	nadaLogWin   = 500 * time.Millisecond
+	nadaQEps     = 10 * time.Millisecond
+	nadaDFilt    = 120 * time.Millisecond
+	nadaGammaMax = 0.5
+	nadaQBound   = 50 * time.Millisecond
+	nadaPLRRef   = 0.01
+	nadaPMRRef   = 0.01
+	nadaDLoss    = 10 * time.Millisecond
+	nadaDMark    = 2 * time.Millisecond
+
+	// nadaMinFilter is the number of queuing delay samples of the minimum
+	// filter.
+	nadaMinFilter = 15
+	// nadaHistorySize is how many sent packets are remembered to match them
+	// with feedback.
+	nadaHistorySize = 1 << 14
+	// nadaL4SGain is the gain of the moving average of the marking
+	// fraction with L4S, as in DCTCP, RFC 8257.
+	nadaL4SGain = 1.0 / 16
+)
+
+// nadaSample is a sent packet reported by feedback.
+type nadaSample struct {
+	at       time.Time
+	sent     time.Time
+	size     int
+	received bool
+	marked   bool
+}
+
+// nada implements the sender side of NADA as described in RFC 8698. The
+// congestion signal is computed on the sender from TWCC or RFC 8888 feedback
+// instead of on the receiver.
+type nada struct {
+	rMin, rMax float64
+	rRef       float64
+
+	epoch time.Time
+	// baseDelay is the minimum one-way delay, separately for TWCC and RFC
+	// 8888 feedback, which use different clocks.
+	baseDelay [2]time.Duration
+	hasBase   [2]bool
+	queueing  []time.Duration
+	window    []nadaSample
+
+	lastUpdate    time.Time
+	lastCongested time.Time
+	xPrev         float64
+
+	// l4s replaces the marking penalty of the congestion signal by a
+	// reduction in proportion to alpha, the moving average of the marking
+	// fraction, at most once per RTT.
+	l4s          bool
+	alpha        float64
+	lastDecrease time.Time
+
+	// stats of the last update
+	rtt    time.Duration
+	dQueue time.Duration
+	xCurr  float64
+	pLoss  float64
+	pMark  float64
+	rRecv  float64
+	rmode  int
+}
+
+func newNADA(c RateControllerConfig) *nada {
+	now := time.Now()
+	return &nada{
+		rMin:  float64(c.MinBitrate),
+		rMax:  float64(c.MaxBitrate),
+		rRef:  math.Max(float64(c.MinBitrate), float64(c.InitialBitrate)),
+		epoch: now,
+		l4s:   c.L4S,
+		// Start in accelerated ramp up.
+		lastCongested: now.Add(-nadaLogWin),
+	}
+}
+
+// update runs the rate update of NADA with the reports of one feedback
+// packet. RTT is approximated by the time since the most recent reported
+// packet was sent, which includes the feedback interval.
+func (n *nada) update(now time.Time, reports []packetReport, samples []nadaSample) {
+	var rtt time.Duration
+	for i, r := range reports {
+		s := samples[i]
+		if !s.received {
+			continue
+		}
+		if d := now.Sub(s.sent); rtt == 0 || d < rtt {
+			rtt = d
+		}
+		if !r.timed {
+			continue
+		}
+		kind := 0
+		if r.twcc {
+			kind = 1
+		}
+		owd := r.arrival - s.sent.Sub(n.epoch)
+		if !n.hasBase[kind] || owd < n.baseDelay[kind] {
+			n.baseDelay[kind], n.hasBase[kind] = owd, true
+		}
+		n.queueing = append(n.queueing, owd-n.baseDelay[kind])
+		if len(n.queueing) > nadaMinFilter {
+			n.queueing = n.queueing[1:]
+		}
+	}
+	if rtt > 0 {
+		if n.rtt == 0 {
+			n.rtt = rtt
+		} else {
+			n.rtt = (7*n.rtt + rtt) / 8
+		}
+	}
+	if len(n.queueing) > 0 {
+		n.dQueue = n.queueing[0]
+		for _, q := range n.queueing[1:] {
+			if q < n.dQueue {
+				n.dQueue = q
+			}
+		}
+	}
+
+	n.window = append(n.window, samples...)
+	for len(n.window) > 0 && now.Sub(n.window[0].at) > nadaLogWin {
+		n.window = n.window[1:]
+	}
+	var lost, marked, received, bytes int
+	for _, s := range n.window {
+		if !s.received {
+			lost++
+			continue
+		}
+		received++
+		bytes += s.size
+		if s.marked {
+			marked++
+		}
+	}
+	n.pLoss, n.pMark = 0, 0
+	if len(n.window) > 0 {
+		n.pLoss = float64(lost) / float64(len(n.window))
+	}
+	if received > 0 {
+		n.pMark = float64(marked) / float64(received)
+	}
+	n.rRecv = float64(bytes*8) / nadaLogWin.Seconds()
+
+	n.xCurr = n.dQueue.Seconds() + nadaDLoss.Seconds()*math.Pow(n.pLoss/nadaPLRRef, 2)
+	if !n.l4s {
+		n.xCurr += nadaDMark.Seconds() * math.Pow(n.pMark/nadaPMRRef, 2)
+	}
+
+	if lost > 0 || marked > 0 || n.dQueue >= nadaQEps {
+		n.lastCongested = now
+	}
+	delta := nadaDelta
+	if !n.lastUpdate.IsZero() {
+		delta = now.Sub(n.lastUpdate)
+		if delta > nadaTau {
+			delta = nadaTau
+		}
+	}
+	n.lastUpdate = now
+
+	if now.Sub(n.lastCongested) >= nadaLogWin {
+		// Accelerated ramp up, RFC 8698, Section 4.3
+		n.rmode = 0
+		gamma := math.Min(nadaGammaMax, nadaQBound.Seconds()/(n.rtt+nadaDelta+nadaDFilt).Seconds())
+		n.rRef = math.Max(n.rRef, (1+gamma)*n.rRecv)
+	} else {
+		// Gradual rate update, RFC 8698, Section 4.3
+		n.rmode = 1
+		tau := nadaTau.Seconds()
+		xOffset := n.xCurr - nadaPrio*nadaXRef.Seconds()*n.rMax/n.rRef
+		xDiff := n.xCurr - n.xPrev
+		n.rRef -= nadaKappa * (delta.Seconds() / tau) * (xOffset / tau) * n.rMax
+		n.rRef -= nadaKappa * nadaEta * (xDiff / tau) * n.rMax
+	}
+	if n.l4s {
+		n.scalableDecrease(now, samples)
+	}
+	n.rRef = math.Max(n.rMin, math.Min(n.rMax, n.rRef))
+	n.xPrev = n.xCurr
+}
+
+// scalableDecrease answers L4S marks like DCTCP and TCP Prague: L4S queues
+// mark early and often, so the reduction is proportional to the marking
+// fraction instead of a fixed back off.
+func (n *nada) scalableDecrease(now time.Time, samples []nadaSample) {
+	var received, marked int
+	for _, s := range samples {
+		if !s.received {
+			continue
+		}
+		received++
+		if s.marked {
+			marked++
+		}
+	}
+	if received == 0 {
+		return
+	}
+	n.alpha += nadaL4SGain * (float64(marked)/float64(received) - n.alpha)
+	if marked > 0 && now.Sub(n.lastDecrease) >= n.rtt {
+		n.rRef *= 1 - n.alpha/2
+		n.lastDecrease = now
+	}
+}
+
+func (n *nada) stats() map[string]interface{} {
+	return map[string]interface{}{
+		"rRef":   int(n.rRef),
+		"rRecv":  int(n.rRecv),
+		"xCurr":  n.xCurr * 1000,
+		"dQueue": float64(n.dQueue.Microseconds()) / 1000.0,
+		"pLoss":  n.pLoss,
+		"pMark":  n.pMark,
+		"rmode":  n.rmode,
+		"alpha":  n.alpha,
+		"rtt":    float64(n.rtt.Microseconds()) / 1000.0,
+	}
+}
+
+type nadaController struct {
+	config RateControllerConfig
+}
+
+func (c *nadaController) Register(r *interceptor.Registry, _ Metricer, onEstimator func(BandwidthEstimator)) error {
+	if err := registerNADA(r, c.config, func(i *nadaInterceptor) {
+		onEstimator(i)
+	}); err != nil {
+		return err
+	}
+	return registerTWCCHeaderExtension(r)
+}
+
+type nadaInterceptorFactory struct {
+	config RateControllerConfig
+	onNew  func(*nadaInterceptor)
+}
+
+func (f *nadaInterceptorFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	i := &nadaInterceptor{
+		nada:    newNADA(f.config),
+		history: map[nadaKey]nadaSent{},
+	}
+	if f.onNew != nil {
+		f.onNew(i)
+	}
+	return i, nil
+}
+
+type nadaKey struct {
+	twcc bool
+	ssrc uint32
+	seq  uint16
+}
+
+type nadaSent struct {
+	sent time.Time
+	size int
+}
+
+// nadaInterceptor runs NADA on the TWCC and RFC 8888 feedback of the packets
+// it sends. TWCC feedback requires the transport-wide sequence number header
+// extension to be added by an interceptor registered after it.
+type nadaInterceptor struct {
+	interceptor.NoOp
+	lock    sync.Mutex
+	nada    *nada
+	history map[nadaKey]nadaSent
+	order   []nadaKey
+}
+
+func (i *nadaInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	var twccID uint8
+	for _, e := range info.RTPHeaderExtensions {
+		if e.URI == transportCCURI {
+			twccID = uint8(e.ID)
+		}
+	}
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		sent := nadaSent{
+			sent: time.Now(),
+			size: header.MarshalSize() + len(payload),
+		}
+		i.lock.Lock()
+		i.remember(nadaKey{ssrc: header.SSRC, seq: header.SequenceNumber}, sent)
+		if ext := header.GetExtension(twccID); twccID != 0 && len(ext) >= 2 {
+			i.remember(nadaKey{twcc: true, seq: uint16(ext[0])<<8 | uint16(ext[1])}, sent)
+		}
+		i.lock.Unlock()
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+// remember adds a sent packet to the history. The caller must hold i.lock.
+func (i *nadaInterceptor) remember(key nadaKey, sent nadaSent) {
+	i.history[key] = sent
+	i.order = append(i.order, key)
+	if len(i.order) > nadaHistorySize {
+		delete(i.history, i.order[0])
+		i.order = i.order[1:]
+	}
+}
+
+func (i *nadaInterceptor) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		pkts, err := attr.GetRTCPPackets(b[:n])
+		if err != nil {
+			return 0, nil, err
+		}
+		if reports := feedbackReports(pkts); len(reports) > 0 {
+			i.onFeedback(time.Now(), reports)
+		}
+		return n, attr, nil
+	})
+}
+
+func (i *nadaInterceptor) onFeedback(now time.Time, reports []packetReport) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	known := reports[:0]
+	samples := make([]nadaSample, 0, len(reports))
+	for _, r := range reports {
+		// Feedback may report packets more than once, only the first
+		// report is used.
+		key := nadaKey{twcc: r.twcc, ssrc: r.ssrc, seq: r.seq}
+		sent, ok := i.history[key]
+		if !ok {
+			continue
+		}
+		delete(i.history, key)
+		known = append(known, r)
+		samples = append(samples, nadaSample{
+			at:       now,
+			sent:     sent.sent,
+			size:     sent.size,
+			received: r.received,
+			marked:   r.ecn == ecnCE,
+		})
+	}
+	if len(samples) > 0 {
+		i.nada.update(now, known, samples)
+	}
+}
+
+func (i *nadaInterceptor) GetTargetBitrate() (int, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return int(i.nada.rRef), nil
+}
+
+func (i *nadaInterceptor) GetStats() map[string]interface{} {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.nada.stats()
+}
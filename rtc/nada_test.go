@@ -0,0 +1,97 @@
+package rtc
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// feedNADA reports 50 packets of 1200 bytes every 100ms for d, which arrive
+// after owd. Every tenth packet is lost if lossy is set, and marked CE if
+// marked is set.
+func feedNADA(n *nada, start time.Time, d, owd time.Duration, lossy, marked bool) time.Time {
+	now := start
+	var seq uint16
+	for ; now.Sub(start) < d; now = now.Add(100 * time.Millisecond) {
+		var reports []packetReport
+		var samples []nadaSample
+		for i := 0; i < 50; i++ {
+			sent := now.Add(-50 * time.Millisecond).Add(time.Duration(i) * time.Millisecond)
+			received := !lossy || i%10 != 0
+			reports = append(reports, packetReport{
+				seq:      seq,
+				received: received,
+				timed:    received,
+				arrival:  sent.Sub(n.epoch) + owd,
+			})
+			samples = append(samples, nadaSample{
+				at:       now,
+				sent:     sent,
+				size:     1200,
+				received: received,
+				marked:   marked && i%10 == 0,
+			})
+			seq++
+		}
+		n.update(now, reports, samples)
+	}
+	return now
+}
+
+func TestNADARateUpdate(t *testing.T) {
+	n := newNADA(RateControllerConfig{
+		InitialBitrate: 1_000_000,
+		MinBitrate:     500_000,
+		MaxBitrate:     10_000_000,
+	})
+	start := n.epoch.Add(time.Second)
+
+	// Without congestion, NADA ramps up to the receive rate of 4.8 Mbps
+	// and beyond.
+	now := feedNADA(n, start, 2*time.Second, 20*time.Millisecond, false, false)
+	assert.Equal(t, 0, n.rmode)
+	assert.Greater(t, n.rRef, 4_800_000.0)
+	ramped := n.rRef
+
+	// Queuing delay and losses make it back off.
+	now = feedNADA(n, now, 2*time.Second, 220*time.Millisecond, true, false)
+	assert.Equal(t, 1, n.rmode)
+	assert.InDelta(t, 0.2, n.dQueue.Seconds(), 0.001)
+	assert.InDelta(t, 0.1, n.pLoss, 0.001)
+	assert.Less(t, n.rRef, ramped/2)
+
+	// As the queue shrinks, the gradual update scales both terms by the
+	// maximum rate, RFC 8698, Equation 7, not by the reference rate that is
+	// far below it now.
+	rRef, xPrev := n.rRef, n.xCurr
+	assert.Less(t, rRef, n.rMax/2)
+	feedNADA(n, now, 100*time.Millisecond, 100*time.Millisecond, true, false)
+	assert.Equal(t, 1, n.rmode)
+	xOffset := n.xCurr - nadaPrio*nadaXRef.Seconds()*n.rMax/rRef
+	xDiff := n.xCurr - xPrev
+	tau := nadaTau.Seconds()
+	expected := rRef - nadaKappa*(nadaDelta.Seconds()/tau)*(xOffset/tau)*n.rMax - nadaKappa*nadaEta*(xDiff/tau)*n.rMax
+	assert.InDelta(t, expected, n.rRef, 1)
+}
+
+func TestNADAL4S(t *testing.T) {
+	n := newNADA(RateControllerConfig{
+		InitialBitrate: 1_000_000,
+		MinBitrate:     500_000,
+		MaxBitrate:     10_000_000,
+		L4S:            true,
+	})
+	start := n.epoch.Add(time.Second)
+	now := feedNADA(n, start, 2*time.Second, 20*time.Millisecond, false, false)
+	ramped := n.rRef
+
+	// Marks don't add to the congestion signal, but reduce the rate in
+	// proportion to the marking fraction of 10%.
+	feedNADA(n, now, 2*time.Second, 20*time.Millisecond, false, true)
+	assert.Equal(t, 1, n.rmode)
+	assert.Less(t, n.xCurr, 0.001)
+	assert.InDelta(t, 0.1*(1-math.Pow(15.0/16, 20)), n.alpha, 0.001)
+	assert.Less(t, n.rRef, ramped*0.8)
+}
@@ -16,16 +16,37 @@ type RTTTracer struct {
 	SmoothedRTT time.Duration
 	RTTVar      time.Duration
 	LatestRTT   time.Duration
+	Cwnd        uint64
+	Lost        uint64
+	// BytesInFlight and PacketsInFlight are the unacknowledged data
+	// counted by the congestion controller.
+	BytesInFlight   uint64
+	PacketsInFlight int
+
+	earlyDatagrams bool
+}
+
+// EarlyDatagrams reports whether the transport parameters restored from a
+// session ticket allow sending datagrams in 0-RTT packets. Unlike
+// quic.Session.ConnectionState, it doesn't block until the handshake
+// completes.
+func (q *RTTTracer) EarlyDatagrams() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.earlyDatagrams
 }
 
 func (q *RTTTracer) Metrics() RTTStats {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 	return RTTStats{
-		MinRTT:      q.MinRTT,
-		SmoothedRTT: q.SmoothedRTT,
-		RTTVar:      q.RTTVar,
-		LatestRTT:   q.LatestRTT,
+		MinRTT:        q.MinRTT,
+		SmoothedRTT:   q.SmoothedRTT,
+		RTTVar:        q.RTTVar,
+		LatestRTT:     q.LatestRTT,
+		Cwnd:          q.Cwnd,
+		Lost:          q.Lost,
+		BytesInFlight: q.BytesInFlight,
 	}
 }
 
@@ -53,6 +74,25 @@ func (q *RTTTracer) updateLatestRTT(rttvar time.Duration) {
 	q.LatestRTT = rttvar
 }
 
+func (q *RTTTracer) updateCwnd(cwnd uint64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.Cwnd = cwnd
+}
+
+func (q *RTTTracer) updateInFlight(bytes uint64, packets int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.BytesInFlight = bytes
+	q.PacketsInFlight = packets
+}
+
+func (q *RTTTracer) addLost() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.Lost++
+}
+
 func NewTracer() *RTTTracer {
 	return &RTTTracer{}
 }
@@ -80,6 +120,9 @@ func (c *ConnectionRTTTracer) ReceivedPacket(hdr *logging.ExtendedHeader, size l
 }
 
 func (c *ConnectionRTTTracer) RestoredTransportParameters(parameters *logging.TransportParameters) {
+	c.t.lock.Lock()
+	defer c.t.lock.Unlock()
+	c.t.earlyDatagrams = parameters.MaxDatagramFrameSize > 0
 }
 
 func (c ConnectionRTTTracer) ReceivedVersionNegotiationPacket(header *logging.Header, numbers []logging.VersionNumber) {
@@ -126,12 +169,17 @@ func (c *ConnectionRTTTracer) UpdatedMetrics(rttStats *logging.RTTStats, cwnd, b
 	if latestRTT != 0 {
 		c.t.updateLatestRTT(latestRTT)
 	}
+	if cwnd != 0 {
+		c.t.updateCwnd(uint64(cwnd))
+	}
+	c.t.updateInFlight(uint64(bytesInFlight), packetsInFlight)
 }
 
 func (c ConnectionRTTTracer) AcknowledgedPacket(logging.EncryptionLevel, logging.PacketNumber) {
 }
 
 func (c ConnectionRTTTracer) LostPacket(level logging.EncryptionLevel, number logging.PacketNumber, reason logging.PacketLossReason) {
+	c.t.addLost()
 }
 
 func (c ConnectionRTTTracer) UpdatedCongestionState(state logging.CongestionState) {
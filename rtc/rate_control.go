@@ -0,0 +1,483 @@
+package rtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/scream/pkg/scream"
+)
+
+// DefaultRateControlInterval is how often the target bitrate is applied if
+// SenderConfig.RateControlInterval is zero.
+const DefaultRateControlInterval = 200 * time.Millisecond
+
+// BandwidthEstimator is the congestion controller of a single sender session.
+type BandwidthEstimator interface {
+	// GetTargetBitrate returns the target bitrate in bits per second.
+	// Negative targets are not applied.
+	GetTargetBitrate() (int, error)
+	// GetStats returns the internal state of the estimator, which is
+	// written to the CC dump.
+	GetStats() map[string]interface{}
+}
+
+// RateController adds a congestion controller to the interceptor chain of
+// every sender session.
+type RateController interface {
+	// Register adds the interceptors of the controller to r. m provides the
+	// metrics of the session's transport. onEstimator has to be called with
+	// the BandwidthEstimator of the session once the interceptors are
+	// built.
+	Register(r *interceptor.Registry, m Metricer, onEstimator func(BandwidthEstimator)) error
+}
+
+type RateControllerConfig struct {
+	InitialBitrate uint
+	// MinBitrate and MaxBitrate default to MIN_BITRATE and MAX_BITRATE.
+	MinBitrate uint
+	MaxBitrate uint
+	// L4S makes rate controllers which support it react to ECN marks in a
+	// scalable way, for media sent with ECT(1).
+	L4S bool
+}
+
+type RateControllerFactory func(RateControllerConfig) (RateController, error)
+
+var (
+	rateControllersLock sync.Mutex
+	rateControllers     = map[string]RateControllerFactory{}
+)
+
+func init() {
+	RegisterRateController("gcc", func(c RateControllerConfig) (RateController, error) {
+		return &gccController{config: c}, nil
+	})
+	RegisterRateController("scream", func(c RateControllerConfig) (RateController, error) {
+		return &screamController{config: c}, nil
+	})
+	RegisterRateController("nada", func(c RateControllerConfig) (RateController, error) {
+		return &nadaController{config: c}, nil
+	})
+	RegisterRateController("quic-cwnd", func(c RateControllerConfig) (RateController, error) {
+		return &cwndController{config: c}, nil
+	})
+	RegisterRateController("remb", func(c RateControllerConfig) (RateController, error) {
+		return &rembController{config: c}, nil
+	})
+}
+
+// RegisterRateController makes a rate controller available to
+// NewRateController. It panics if name is registered twice.
+func RegisterRateController(name string, f RateControllerFactory) {
+	rateControllersLock.Lock()
+	defer rateControllersLock.Unlock()
+	if _, ok := rateControllers[name]; ok {
+		panic(fmt.Sprintf("rate controller %v registered twice", name))
+	}
+	rateControllers[name] = f
+}
+
+// RateControllers returns the names of all registered rate controllers.
+func RateControllers() []string {
+	rateControllersLock.Lock()
+	defer rateControllersLock.Unlock()
+	names := make([]string, 0, len(rateControllers))
+	for name := range rateControllers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func NewRateController(name string, c RateControllerConfig) (RateController, error) {
+	rateControllersLock.Lock()
+	f, ok := rateControllers[name]
+	rateControllersLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown rate controller: %v, available: %v", name, strings.Join(RateControllers(), ", "))
+	}
+	if c.MinBitrate == 0 {
+		c.MinBitrate = MIN_BITRATE
+	}
+	if c.MaxBitrate == 0 {
+		c.MaxBitrate = MAX_BITRATE
+	}
+	return f(c)
+}
+
+type gccController struct {
+	config RateControllerConfig
+}
+
+func (c *gccController) Register(r *interceptor.Registry, _ Metricer, onEstimator func(BandwidthEstimator)) error {
+	if err := registerGCC(r, c.config, func(_ string, bwe cc.BandwidthEstimator) {
+		onEstimator(gccEstimator{bwe})
+	}); err != nil {
+		return err
+	}
+	return registerTWCCHeaderExtension(r)
+}
+
+type gccEstimator struct {
+	cc.BandwidthEstimator
+}
+
+func (e gccEstimator) GetTargetBitrate() (int, error) {
+	return e.BandwidthEstimator.GetTargetBitrate(), nil
+}
+
+type screamController struct {
+	config RateControllerConfig
+}
+
+func (c *screamController) Register(r *interceptor.Registry, _ Metricer, onEstimator func(BandwidthEstimator)) error {
+	streams := &ssrcRecorder{}
+	if err := registerSCReAM(r, c.config, func(_ string, bwe scream.BandwidthEstimator) {
+		onEstimator(&screamEstimator{bwe: bwe, streams: streams})
+	}); err != nil {
+		return err
+	}
+	r.Add(streams)
+	// The header extension allows to translate TWCC feedback.
+	return registerTWCCHeaderExtension(r)
+}
+
+// setSCReAMAttributes sets the stream attributes which register a flow with
+// SCReAM with the bounds of c and a priority in proportion to its weight
+// relative to maxWeight, the largest weight of the flows. Since SCReAM can't
+// change the priority of a registered stream, flows keep it until the next
+// reconnect.
+func setSCReAMAttributes(attributes interceptor.Attributes, c FlowConfig, maxWeight int) {
+	attributes[scream.PriorityAttribute] = float64(weight(c)) / float64(maxWeight)
+	if c.MinBitrate > 0 {
+		attributes[scream.MinBitrateAttribute] = float64(c.MinBitrate)
+	}
+	if c.MaxBitrate > 0 {
+		attributes[scream.MaxBitrateAttribute] = float64(c.MaxBitrate)
+	}
+}
+
+var errSCReAMLoss = errors.New("scream detected a loss")
+
+// screamEstimator sums up the targets SCReAM computes for each stream.
+type screamEstimator struct {
+	bwe     scream.BandwidthEstimator
+	streams *ssrcRecorder
+}
+
+func (e *screamEstimator) GetTargetBitrate() (int, error) {
+	total := 0
+	for _, ssrc := range e.streams.ssrcs() {
+		target, err := e.bwe.GetTargetBitrate(ssrc)
+		if err != nil {
+			return 0, err
+		}
+		if target == -1 {
+			return 0, errSCReAMLoss
+		}
+		if target < 0 {
+			return target, nil
+		}
+		total += target
+	}
+	return total, nil
+}
+
+func (e *screamEstimator) GetStats() map[string]interface{} {
+	return e.bwe.GetStats()
+}
+
+// ssrcRecorder is an interceptor which keeps track of the SSRCs of the local
+// streams.
+type ssrcRecorder struct {
+	interceptor.NoOp
+	lock    sync.Mutex
+	streams []uint32
+}
+
+func (r *ssrcRecorder) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return r, nil
+}
+
+func (r *ssrcRecorder) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.streams = append(r.streams, info.SSRC)
+	return writer
+}
+
+func (r *ssrcRecorder) UnbindLocalStream(info *interceptor.StreamInfo) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, ssrc := range r.streams {
+		if ssrc == info.SSRC {
+			r.streams = append(r.streams[:i], r.streams[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *ssrcRecorder) ssrcs() []uint32 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]uint32{}, r.streams...)
+}
+
+// feedbackNotifier is an interceptor which signals feedback after the
+// interceptors registered before it have read it.
+type feedbackNotifier struct {
+	interceptor.NoOp
+	feedback chan struct{}
+}
+
+func newFeedbackNotifier() *feedbackNotifier {
+	return &feedbackNotifier{
+		feedback: make(chan struct{}, 1),
+	}
+}
+
+func (n *feedbackNotifier) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return n, nil
+}
+
+func (n *feedbackNotifier) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		i, attr, err := reader.Read(b, a)
+		if err == nil {
+			select {
+			case n.feedback <- struct{}{}:
+			default:
+			}
+		}
+		return i, attr, err
+	})
+}
+
+// FlowConfig sets how a flow shares the target bitrate with the other flows
+// of a Sender.
+type FlowConfig struct {
+	// Weight is the share of the flow in the bitrate left after all
+	// minimums are met, relative to the weights of the other flows. Zero
+	// counts as one.
+	Weight uint
+	// Priority decides which flows get their minimum first if the target
+	// does not cover all minimums. Higher values come first.
+	Priority int
+	// MinBitrate and MaxBitrate bound the bitrate of the flow. A zero
+	// MaxBitrate means no bound.
+	MinBitrate uint
+	MaxBitrate uint
+}
+
+// minFlowBitrate is the bitrate of flows whose minimum is not covered by the
+// target, since encoders can't be set to zero.
+const minFlowBitrate = 10_000
+
+type allocation struct {
+	src    MediaSource
+	config FlowConfig
+}
+
+// bitrateAllocator splits the target bitrate of the rate controller across
+// the media sources according to their FlowConfigs.
+type bitrateAllocator struct {
+	lock      sync.Mutex
+	pipelines []allocation
+	// target is the most recent target, which is split again when flows are
+	// added or removed. Zero if there is none yet.
+	target int
+	// fecOverhead is the ratio of FEC to media bytes, the sources share
+	// target / (1 + fecOverhead).
+	fecOverhead float64
+}
+
+func (a *bitrateAllocator) addPipeline(p MediaSource, c FlowConfig) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.pipelines = append(a.pipelines, allocation{src: p, config: c})
+	a.apply()
+}
+
+func (a *bitrateAllocator) removePipeline(p MediaSource) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for i, q := range a.pipelines {
+		if q.src == p {
+			a.pipelines = append(a.pipelines[:i], a.pipelines[i+1:]...)
+			a.apply()
+			return
+		}
+	}
+}
+
+func (a *bitrateAllocator) setBitRate(target int) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.target = target
+	a.apply()
+}
+
+func (a *bitrateAllocator) setFECOverhead(overhead float64) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if overhead == a.fecOverhead {
+		return
+	}
+	a.fecOverhead = overhead
+	a.apply()
+}
+
+// apply sets the bitrates of all sources. The caller must hold a.lock.
+func (a *bitrateAllocator) apply() {
+	if a.target == 0 || len(a.pipelines) == 0 {
+		return
+	}
+	configs := make([]FlowConfig, len(a.pipelines))
+	for i, p := range a.pipelines {
+		configs[i] = p.config
+	}
+	target := int(float64(a.target) / (1 + a.fecOverhead))
+	for i, rate := range allocate(target, configs) {
+		if rate < minFlowBitrate {
+			rate = minFlowBitrate
+		}
+		a.pipelines[i].src.SetBitRate(uint(rate))
+	}
+}
+
+// allocate splits target across flows. Minimums are met in order of
+// priority, the rest is shared by weight without exceeding the maximums.
+// Bitrate that no flow can take is left unused.
+func allocate(target int, flows []FlowConfig) []int {
+	rates := make([]int, len(flows))
+	byPriority := make([]int, len(flows))
+	for i := range byPriority {
+		byPriority[i] = i
+	}
+	sort.SliceStable(byPriority, func(i, j int) bool {
+		return flows[byPriority[i]].Priority > flows[byPriority[j]].Priority
+	})
+	remaining := target
+	for _, i := range byPriority {
+		lower := int(flows[i].MinBitrate)
+		if upper := int(flows[i].MaxBitrate); upper > 0 && lower > upper {
+			lower = upper
+		}
+		if lower > remaining {
+			lower = remaining
+		}
+		rates[i] = lower
+		remaining -= lower
+	}
+
+	var open []int
+	for i, f := range flows {
+		if f.MaxBitrate == 0 || rates[i] < int(f.MaxBitrate) {
+			open = append(open, i)
+		}
+	}
+	// Flows which reach their maximum give their share to the others in
+	// the next round.
+	for remaining > 0 && len(open) > 0 {
+		var weights int
+		for _, i := range open {
+			weights += weight(flows[i])
+		}
+		var next []int
+		shared := 0
+		for _, i := range open {
+			share := remaining * weight(flows[i]) / weights
+			if upper := int(flows[i].MaxBitrate); upper > 0 && rates[i]+share >= upper {
+				share = upper - rates[i]
+			} else {
+				next = append(next, i)
+			}
+			rates[i] += share
+			shared += share
+		}
+		remaining -= shared
+		if len(next) == len(open) {
+			break
+		}
+		open = next
+	}
+	return rates
+}
+
+func weight(c FlowConfig) int {
+	if c.Weight == 0 {
+		return 1
+	}
+	return int(c.Weight)
+}
+
+// run applies the target of bwe every interval and, if feedback is not nil,
+// whenever feedback arrives, until ctx is done. Each update is written to
+// dump.
+func (a *bitrateAllocator) run(ctx context.Context, bwe BandwidthEstimator, interval time.Duration, feedback <-chan struct{}, dump io.Writer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-feedback:
+		}
+		target, err := bwe.GetTargetBitrate()
+		if err != nil {
+			log.Printf("failed to get target bitrate: %v\n", err)
+			continue
+		}
+		if target < 0 {
+			log.Printf("got negative target bitrate: %v\n", target)
+			continue
+		}
+		if dump != nil {
+			writeCCStats(dump, time.Now(), target, a.withFECOverhead(bwe.GetStats()))
+		}
+		a.setBitRate(target)
+	}
+}
+
+// withFECOverhead returns stats with the FEC overhead added, if any.
+func (a *bitrateAllocator) withFECOverhead(stats map[string]interface{}) map[string]interface{} {
+	a.lock.Lock()
+	overhead := a.fecOverhead
+	a.lock.Unlock()
+	if overhead == 0 {
+		return stats
+	}
+	withFEC := make(map[string]interface{}, len(stats)+1)
+	for k, v := range stats {
+		withFEC[k] = v
+	}
+	withFEC["fec_overhead"] = overhead
+	return withFEC
+}
+
+// writeCCStats writes a line of tab separated key=value pairs to w, starting
+// with the time and the target bitrate, followed by stats sorted by key.
+func writeCCStats(w io.Writer, now time.Time, target int, stats map[string]interface{}) {
+	keys := make([]string, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	fmt.Fprintf(&b, "time=%v\ttarget=%v", now.Format(time.RFC3339Nano), target)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\t%v=%v", k, stats[k])
+	}
+	b.WriteByte('\n')
+	io.WriteString(w, b.String())
+}
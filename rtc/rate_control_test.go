@@ -0,0 +1,134 @@
+package rtc
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/scream/pkg/scream"
+	"github.com/stretchr/testify/assert"
+)
+
+type constantEstimator int
+
+func (e constantEstimator) GetTargetBitrate() (int, error) {
+	return int(e), nil
+}
+
+func (e constantEstimator) GetStats() map[string]interface{} {
+	return map[string]interface{}{"b": 2, "a": "x"}
+}
+
+type constantController struct {
+	config RateControllerConfig
+}
+
+func (c *constantController) Register(_ *interceptor.Registry, _ Metricer, onEstimator func(BandwidthEstimator)) error {
+	onEstimator(constantEstimator(c.config.InitialBitrate))
+	return nil
+}
+
+type bitrateSource struct {
+	lock      sync.Mutex
+	bitrate   uint
+	keyframes int
+}
+
+func (s *bitrateSource) Read([]byte) (int, error) {
+	return 0, nil
+}
+
+func (s *bitrateSource) SetBitRate(bitrate uint) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.bitrate = bitrate
+}
+
+func (s *bitrateSource) ForceKeyframe() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.keyframes++
+	return nil
+}
+
+func (s *bitrateSource) getBitRate() uint {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.bitrate
+}
+
+func init() {
+	RegisterRateController("constant", func(c RateControllerConfig) (RateController, error) {
+		return &constantController{config: c}, nil
+	})
+}
+
+func TestRateControllerRegistry(t *testing.T) {
+	assert.Subset(t, RateControllers(), []string{"constant", "gcc", "scream"})
+	assert.Panics(t, func() {
+		RegisterRateController("constant", nil)
+	})
+	_, err := NewRateController("unknown", RateControllerConfig{})
+	assert.Error(t, err)
+
+	rc, err := NewRateController("constant", RateControllerConfig{InitialBitrate: 3_000_000})
+	assert.NoError(t, err)
+	var bwe BandwidthEstimator
+	assert.NoError(t, rc.Register(&interceptor.Registry{}, nil, func(e BandwidthEstimator) {
+		bwe = e
+	}))
+
+	src := &bitrateSource{}
+	a := &bitrateAllocator{}
+	a.addPipeline(src, FlowConfig{})
+	a.addPipeline(&bitrateSource{}, FlowConfig{})
+	feedback := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.run(ctx, bwe, time.Hour, feedback, nil)
+
+	feedback <- struct{}{}
+	assert.Eventually(t, func() bool {
+		return src.getBitRate() == 1_500_000
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWriteCCStats(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2022, 10, 25, 12, 0, 0, 0, time.UTC)
+	writeCCStats(&buf, now, 1000, constantEstimator(0).GetStats())
+	assert.Equal(t, "time=2022-10-25T12:00:00Z\ttarget=1000\ta=x\tb=2\n", buf.String())
+}
+
+func TestAllocate(t *testing.T) {
+	assert.Equal(t, []int{1_500_000, 1_500_000}, allocate(3_000_000, []FlowConfig{{}, {}}))
+	assert.Equal(t, []int{3_000_000, 1_000_000}, allocate(4_000_000, []FlowConfig{{Weight: 3}, {Weight: 1}}))
+
+	// The minimum of the higher priority flow is met first.
+	flows := []FlowConfig{
+		{MinBitrate: 500_000},
+		{Priority: 1, MinBitrate: 800_000},
+	}
+	assert.Equal(t, []int{200_000, 800_000}, allocate(1_000_000, flows))
+	assert.Equal(t, []int{600_000, 900_000}, allocate(1_500_000, flows))
+
+	// A flow at its maximum leaves the rest to the others.
+	assert.Equal(t, []int{2_000_000, 3_000_000}, allocate(5_000_000, []FlowConfig{{MaxBitrate: 2_000_000}, {}}))
+	assert.Equal(t, []int{1_000_000, 2_000_000}, allocate(5_000_000, []FlowConfig{{MaxBitrate: 1_000_000}, {MaxBitrate: 2_000_000}}))
+}
+
+func TestSetSCReAMAttributes(t *testing.T) {
+	attributes := interceptor.Attributes{}
+	setSCReAMAttributes(attributes, FlowConfig{Weight: 1, MaxBitrate: 1_500_000}, 4)
+	assert.Equal(t, 0.25, attributes[scream.PriorityAttribute])
+	assert.Nil(t, attributes[scream.MinBitrateAttribute])
+	assert.Equal(t, float64(1_500_000), attributes[scream.MaxBitrateAttribute])
+
+	attributes = interceptor.Attributes{}
+	setSCReAMAttributes(attributes, FlowConfig{Weight: 4, MinBitrate: 1_000_000}, 4)
+	assert.Equal(t, 1.0, attributes[scream.PriorityAttribute])
+	assert.Equal(t, float64(1_000_000), attributes[scream.MinBitrateAttribute])
+}
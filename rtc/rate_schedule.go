@@ -0,0 +1,173 @@
+package rtc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+)
+
+type rateSchedulePoint struct {
+	offset  time.Duration
+	bitrate int
+	// ramp interpolates linearly from the previous point up to this one
+	// instead of stepping at offset.
+	ramp bool
+}
+
+// RateSchedule is a sequence of target bitrates over time.
+type RateSchedule struct {
+	points []rateSchedulePoint
+	// period is the offset at which the schedule starts over, zero if it
+	// doesn't loop.
+	period time.Duration
+}
+
+// ParseRateSchedule reads a schedule in CSV format with one point per line:
+//
+//	offset,bitrate[,step|ramp]
+//
+// Offsets are durations like 1.5s or plain seconds, bitrates are in bits per
+// second. A point steps to its bitrate at its offset, or, with ramp, changes
+// linearly from the previous point to reach its bitrate at its offset. A last
+// line of the form "offset,loop" starts the schedule over at offset. Empty
+// lines and lines starting with # are ignored.
+func ParseRateSchedule(r io.Reader) (*RateSchedule, error) {
+	s := &RateSchedule{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if s.period != 0 {
+			return nil, fmt.Errorf("line %v: points after loop", line)
+		}
+		fields := strings.Split(text, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %v: expected offset,bitrate[,step|ramp]", line)
+		}
+		offset, err := parseScheduleOffset(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", line, err)
+		}
+		if n := len(s.points); n > 0 && offset < s.points[n-1].offset {
+			return nil, fmt.Errorf("line %v: offsets must not decrease", line)
+		}
+		if fields[1] == "loop" {
+			if len(s.points) == 0 || offset <= 0 {
+				return nil, fmt.Errorf("line %v: loop needs a positive offset after the first point", line)
+			}
+			s.period = offset
+			continue
+		}
+		bitrate, err := strconv.ParseUint(fields[1], 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: invalid bitrate: %w", line, err)
+		}
+		p := rateSchedulePoint{
+			offset:  offset,
+			bitrate: int(bitrate),
+		}
+		if len(fields) == 3 {
+			switch fields[2] {
+			case "step":
+			case "ramp":
+				p.ramp = true
+			default:
+				return nil, fmt.Errorf("line %v: unknown mode %v", line, fields[2])
+			}
+		}
+		s.points = append(s.points, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(s.points) == 0 {
+		return nil, fmt.Errorf("empty rate schedule")
+	}
+	return s, nil
+}
+
+func parseScheduleOffset(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset: %v", s)
+	}
+	return d, nil
+}
+
+// At returns the bitrate at offset d from the start of the schedule. Before
+// the first point, the bitrate of the first point applies.
+func (s *RateSchedule) At(d time.Duration) int {
+	if s.period > 0 {
+		d %= s.period
+	}
+	prev := s.points[0]
+	for _, p := range s.points[1:] {
+		if d < p.offset {
+			if p.ramp && d > prev.offset {
+				progress := float64(d-prev.offset) / float64(p.offset-prev.offset)
+				return prev.bitrate + int(progress*float64(p.bitrate-prev.bitrate))
+			}
+			return prev.bitrate
+		}
+		prev = p
+	}
+	return prev.bitrate
+}
+
+// NewRateScheduleController returns a RateController which follows schedule
+// from the time the first session starts, across reconnects.
+func NewRateScheduleController(schedule *RateSchedule) RateController {
+	return &scheduleController{
+		schedule: schedule,
+	}
+}
+
+type scheduleController struct {
+	schedule *RateSchedule
+
+	lock  sync.Mutex
+	start time.Time
+}
+
+func (c *scheduleController) Register(_ *interceptor.Registry, _ Metricer, onEstimator func(BandwidthEstimator)) error {
+	c.lock.Lock()
+	if c.start.IsZero() {
+		c.start = time.Now()
+	}
+	start := c.start
+	c.lock.Unlock()
+	onEstimator(&scheduleEstimator{
+		schedule: c.schedule,
+		start:    start,
+	})
+	return nil
+}
+
+type scheduleEstimator struct {
+	schedule *RateSchedule
+	start    time.Time
+}
+
+func (e *scheduleEstimator) GetTargetBitrate() (int, error) {
+	return e.schedule.At(time.Since(e.start)), nil
+}
+
+func (e *scheduleEstimator) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"offset": time.Since(e.start).Seconds(),
+	}
+}
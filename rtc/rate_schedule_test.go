@@ -0,0 +1,46 @@
+package rtc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateSchedule(t *testing.T) {
+	s, err := ParseRateSchedule(strings.NewReader(`
+# offset,bitrate[,step|ramp]
+0,1000000
+10s,2000000,ramp
+20,500000
+30s,loop
+`))
+	assert.NoError(t, err)
+	for _, tc := range []struct {
+		offset  time.Duration
+		bitrate int
+	}{
+		{0, 1_000_000},
+		{5 * time.Second, 1_500_000},
+		{10 * time.Second, 2_000_000},
+		{19 * time.Second, 2_000_000},
+		{20 * time.Second, 500_000},
+		{35 * time.Second, 1_500_000},
+	} {
+		assert.Equal(t, tc.bitrate, s.At(tc.offset), tc.offset.String())
+	}
+
+	for _, invalid := range []string{
+		"",
+		"0",
+		"x,1000",
+		"10,1000\n5,1000",
+		"0,1000,jump",
+		"0,loop",
+		"0,1000\n10,loop\n20,1000",
+	} {
+		_, err := ParseRateSchedule(strings.NewReader(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
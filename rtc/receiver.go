@@ -3,13 +3,20 @@ package rtc
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/quicvarint"
 	"github.com/pion/interceptor"
 	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
 )
 
 type Transport interface {
@@ -19,27 +26,92 @@ type Transport interface {
 	Metricer
 }
 
+// ecnTransport is implemented by transports which read the ECN codepoint of
+// received messages.
+type ecnTransport interface {
+	ReceiveMessageECN() ([]byte, ECN, error)
+}
+
 type MediaSink interface {
 	io.WriteCloser
 }
 
 type receiveFlow struct {
 	media  io.WriteCloser
+	info   *interceptor.StreamInfo
 	reader interceptor.RTPReader
+	// payloadType is the payload type of the last media packet, restored
+	// in retransmissions.
+	payloadType uint8
+	// fec recovers lost packets from FEC packets, nil if FEC is disabled.
+	fec *fecDecoder
 }
 
 type Receiver struct {
 	session     Transport
-	flows       map[uint64]*receiveFlow
 	interceptor interceptor.Interceptor
 	wg          sync.WaitGroup
+
+	// serializes packets arriving on datagrams and streams and protects
+	// flows
+	lock       sync.Mutex
+	flows      map[uint64]*receiveFlow
+	onFlow     MediaSinkFactory
+	sinks      *SinkPool
+	rtcpReader interceptor.RTCPReader
+
+	// paths is set on Receivers of the paths of a multipath sender, which
+	// only forward to the Receiver of the group, see multipathGroups.
+	paths *multipathGroups
+	// dedup drops duplicate packets arriving on multiple paths.
+	dedup *deduplicator
+	// fec enables recovery from FEC packets for new flows.
+	fec bool
+	// stats collects the statistics of the flows from RTCP reports, nil
+	// on the Receivers of the paths of a multipath sender.
+	stats *reportStats
 }
 
 type ReceiverConfig struct {
 	RTPDump  io.Writer
 	RTCPDump io.Writer
 	RFC8888  bool
-	TWCC     bool
+	// RFC8888Format is the format of RFC 8888 feedback, CCFBDraft by
+	// default, which SCReAM expects.
+	RFC8888Format CCFBFormat
+	// RFC8888Interval is how often RFC 8888 feedback is sent,
+	// DefaultCCFBInterval if zero.
+	RFC8888Interval time.Duration
+	// RFC8888Dump receives every RFC 8888 feedback packet sent.
+	RFC8888Dump io.Writer
+	TWCC        bool
+	// RTCPReports enables RTCP receiver reports.
+	RTCPReports bool
+	// REMB enables receive side bandwidth estimation, sent to the sender
+	// in RTCP REMB packets.
+	REMB bool
+	// KeyframeRequest asks the sender for a keyframe with PLI or FIR when
+	// a packet is missing for longer than KeyframeRequestDelay,
+	// DefaultKeyframeRequestDelay if zero.
+	KeyframeRequest      KeyframeRequest
+	KeyframeRequestDelay time.Duration
+	// NACK sends NACKs for missing packets, RFC 4585, and restores the
+	// retransmissions the sender answers with, RFC 4588.
+	NACK bool
+	// FEC recovers lost packets from FlexFEC packets, RFC 8627, before
+	// passing them to the sink.
+	FEC bool
+
+	// SRTP enables SRTP and SRTCP, see SRTPTransport.
+	SRTP *SRTPConfig
+
+	// Sinks lets reconnecting senders continue writing to their sinks. If
+	// nil, every connection gets new sinks.
+	Sinks *SinkPool
+
+	// Multipath merges the connections of senders using a
+	// MultipathTransport.
+	Multipath bool
 }
 
 func GstreamerReceiverFactory(c ReceiverConfig) (ReceiverFactory, error) {
@@ -48,7 +120,7 @@ func GstreamerReceiverFactory(c ReceiverConfig) (ReceiverFactory, error) {
 		return nil, err
 	}
 	if c.RFC8888 {
-		if err := registerRFC8888(&ir); err != nil {
+		if err := registerRFC8888(&ir, c.RFC8888Format, c.RFC8888Interval, c.RFC8888Dump); err != nil {
 			return nil, err
 		}
 	}
@@ -57,20 +129,64 @@ func GstreamerReceiverFactory(c ReceiverConfig) (ReceiverFactory, error) {
 			return nil, err
 		}
 	}
-	return func(session Transport, sinkFactory MediaSinkFactory) (*Receiver, error) {
-		interceptor, err := ir.Build("")
+	if c.RTCPReports {
+		if err := registerReceiverReports(&ir); err != nil {
+			return nil, err
+		}
+	}
+	if c.REMB {
+		if err := registerREMB(&ir); err != nil {
+			return nil, err
+		}
+	}
+	if c.KeyframeRequest != NoKeyframeRequest {
+		if err := registerKeyframeRequests(&ir, c.KeyframeRequest, c.KeyframeRequestDelay); err != nil {
+			return nil, err
+		}
+	}
+	if c.NACK {
+		if err := registerNACK(&ir); err != nil {
+			return nil, err
+		}
+	}
+	factory := func(session Transport, sinkFactory MediaSinkFactory) (*Receiver, error) {
+		i, err := ir.Build("")
 		if err != nil {
 			return nil, err
 		}
-		sink, err := sinkFactory()
+		stats := newReportStats()
+		if c.SRTP != nil {
+			session, err = NewSRTPTransport(session, false, *c.SRTP)
+			if err != nil {
+				return nil, err
+			}
+		}
+		receiver, err := newReceiver(session, interceptor.NewChain([]interceptor.Interceptor{stats, i}))
 		if err != nil {
 			return nil, err
 		}
-		receiver, err := newReceiver(session, interceptor)
+		receiver.OnFlow(sinkFactory)
+		receiver.sinks = c.Sinks
+		receiver.stats = stats
+		receiver.fec = c.FEC
+		if c.NACK || c.FEC {
+			// Drops retransmitted and recovered packets which arrived
+			// after all.
+			receiver.dedup = newDeduplicator()
+		}
+		return receiver, nil
+	}
+	if !c.Multipath {
+		return factory, nil
+	}
+	groups := newMultipathGroups(factory)
+	return func(session Transport, sinkFactory MediaSinkFactory) (*Receiver, error) {
+		receiver, err := newReceiver(session, &interceptor.NoOp{})
 		if err != nil {
 			return nil, err
 		}
-		receiver.setFlow(0, sink)
+		receiver.OnFlow(sinkFactory)
+		receiver.paths = groups
 		return receiver, nil
 	}, nil
 }
@@ -84,31 +200,61 @@ func newReceiver(session Transport, interceptor interceptor.Interceptor) (*Recei
 	}, nil
 }
 
-func (r *Receiver) setFlow(id uint64, pipeline io.WriteCloser) {
-	streamReader := r.interceptor.BindRemoteStream(&interceptor.StreamInfo{
+// OnFlow sets the factory which is called to create a MediaSink whenever a
+// packet with a new flow ID arrives.
+func (r *Receiver) OnFlow(f MediaSinkFactory) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.onFlow = f
+}
+
+// RemoveFlow closes the MediaSink of the flow with the given ID. If more
+// packets of the flow arrive later, a new sink is created.
+func (r *Receiver) RemoveFlow(id uint64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	flow, ok := r.flows[id]
+	if !ok {
+		return fmt.Errorf("unknown flow ID: %v", id)
+	}
+	delete(r.flows, id)
+	r.interceptor.UnbindRemoteStream(flow.info)
+	return flow.media.Close()
+}
+
+func (r *Receiver) addFlow(id uint64, ssrc uint32, pipeline io.WriteCloser) *receiveFlow {
+	info := &interceptor.StreamInfo{
 		ID:                  "",
 		Attributes:          map[interface{}]interface{}{},
-		SSRC:                0,
+		SSRC:                ssrc,
 		PayloadType:         0,
-		RTPHeaderExtensions: []interceptor.RTPHeaderExtension{{URI: transportCCURI, ID: 1}},
+		RTPHeaderExtensions: []interceptor.RTPHeaderExtension{{URI: transportCCURI, ID: transportCCExtensionID}},
 		MimeType:            "",
-		ClockRate:           0,
+		ClockRate:           videoClockRate,
 		Channels:            0,
 		SDPFmtpLine:         "",
 		RTCPFeedback:        []interceptor.RTCPFeedback{{Type: "ack", Parameter: "ccfb"}},
-	}, interceptor.RTPReaderFunc(func(b []byte, _ interceptor.Attributes) (int, interceptor.Attributes, error) {
+	}
+	streamReader := r.interceptor.BindRemoteStream(info, interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
 		n, err := pipeline.Write(b)
 		if err != nil {
 			return n, nil, err
 		}
 		//log.Printf("%v bytes written to pipeline\n", n)
-		return len(b), nil, nil
+		return len(b), a, nil
 	}))
 
-	r.flows[id] = &receiveFlow{
+	flow := &receiveFlow{
 		media:  pipeline,
+		info:   info,
 		reader: streamReader,
 	}
+	if r.fec {
+		flow.fec = newFECDecoder(ssrc)
+	}
+	r.flows[id] = flow
+	return flow
 }
 
 func (r *Receiver) run(ctx context.Context) (err error) {
@@ -123,16 +269,37 @@ func (r *Receiver) run(ctx context.Context) (err error) {
 		err = err1
 	}()
 
+	if r.paths != nil {
+		return r.paths.forward(ctx, r.session, r.onFlow)
+	}
+
 	_ = r.interceptor.BindRTCPWriter(interceptor.RTCPWriterFunc(r.rtcpWriter))
 
+	r.lock.Lock()
+	r.rtcpReader = r.interceptor.BindRTCPReader(interceptor.RTCPReaderFunc(func(in []byte, _ interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return len(in), nil, nil
+	}))
+	r.lock.Unlock()
+
 	defer r.interceptor.Close()
 
+	if st, ok := r.session.(StreamTransport); ok {
+		go r.acceptStreams(ctx, st)
+	}
+	receive := func() ([]byte, ECN, error) {
+		buf, err := r.session.ReceiveMessage()
+		return buf, NotECT, err
+	}
+	if et, ok := r.session.(ecnTransport); ok {
+		receive = et.ReceiveMessageECN
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			buf, err := r.session.ReceiveMessage()
+			buf, ecn, err := receive()
 			if err != nil {
 				return err
 			}
@@ -144,18 +311,186 @@ func (r *Receiver) run(ctx context.Context) (err error) {
 				continue
 			}
 			n := quicvarint.Len(id)
-			packet := buf[n:]
-			flow, ok := r.flows[id]
-			if !ok {
-				log.Printf("got datagram with unknown flow ID (%v), dropping datagram\n", id)
-				continue
-			}
-			//log.Printf("writing %v bytes to flow %v\n", len(packet), id)
-			if _, _, err := flow.reader.Read(packet, nil); err != nil {
-				panic(err)
-			}
-			//log.Printf("%v bytes written to pipeline\n", len(buf))
+			r.handlePacketECN(id, buf[n:], ecn)
+		}
+	}
+}
+
+func (r *Receiver) handlePacket(id uint64, packet []byte) {
+	r.handlePacketECN(id, packet, NotECT)
+}
+
+// handlePacketECN passes packet to flow id. ecn is the ECN codepoint of the
+// packet, which is added to the attributes of the RTP reader. Retransmissions
+// are restored to the original packet and marked with rtxAttribute.
+func (r *Receiver) handlePacketECN(id uint64, packet []byte, ecn ECN) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if isRTCP(packet) {
+		r.handleRTCP(packet)
+		return
+	}
+
+	rtx := isRTX(packet)
+	fec := isFEC(packet)
+	flow, ok := r.flows[id]
+	if !ok {
+		if rtx || fec {
+			log.Printf("got retransmission or FEC packet of unknown flow ID (%v), dropping packet\n", id)
+			return
+		}
+		if r.onFlow == nil {
+			log.Printf("got packet with unknown flow ID (%v), dropping packet\n", id)
+			return
+		}
+		var header rtp.Header
+		if _, err := header.Unmarshal(packet); err != nil {
+			log.Printf("failed to unmarshal RTP header of new flow %v: %v, dropping packet\n", id, err)
+			return
+		}
+		sink, err := r.newSink(id, header.SSRC)
+		if err != nil {
+			log.Printf("failed to create sink for flow %v: %v, dropping packet\n", id, err)
+			return
+		}
+		log.Printf("new flow: %v, SSRC: %v\n", id, header.SSRC)
+		flow = r.addFlow(id, header.SSRC, sink)
+	}
+	if fec {
+		if flow.fec == nil {
+			return
+		}
+		recovered, err := flow.fec.addFEC(packet)
+		if err != nil {
+			log.Printf("failed to parse FEC packet of flow %v: %v, dropping packet\n", id, err)
 		}
+		r.deliverRecovered(id, flow, recovered)
+		return
+	}
+	if rtx {
+		var err error
+		if packet, err = flow.restoreRTX(packet); err != nil {
+			log.Printf("failed to restore retransmission of flow %v: %v, dropping packet\n", id, err)
+			return
+		}
+	} else if len(packet) >= 2 {
+		flow.payloadType = packet[1] & 0x7f
+	}
+	if r.duplicate(id, packet) {
+		return
+	}
+	var recovered [][]byte
+	if flow.fec != nil && !rtx {
+		recovered = flow.fec.add(packet)
+	}
+	//log.Printf("writing %v bytes to flow %v\n", len(packet), id)
+	attr := interceptor.Attributes{}
+	if ecn != NotECT {
+		attr.Set(ecnAttribute{}, uint8(ecn))
+	}
+	if rtx {
+		attr.Set(rtxAttribute{}, true)
+	}
+	if _, _, err := flow.reader.Read(packet, attr); err != nil {
+		panic(err)
+	}
+	//log.Printf("%v bytes written to pipeline\n", len(buf))
+	r.deliverRecovered(id, flow, recovered)
+}
+
+// deliverRecovered passes the packets recovered by FEC to flow. The caller
+// must hold r.lock.
+func (r *Receiver) deliverRecovered(id uint64, flow *receiveFlow, recovered [][]byte) {
+	for _, packet := range recovered {
+		if r.duplicate(id, packet) {
+			continue
+		}
+		if _, _, err := flow.reader.Read(packet, interceptor.Attributes{fecAttribute{}: true}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// duplicate reports whether packet of flow id arrived before. The caller must
+// hold r.lock.
+func (r *Receiver) duplicate(id uint64, packet []byte) bool {
+	return r.dedup != nil && len(packet) >= 4 && r.dedup.duplicate(id, binary.BigEndian.Uint16(packet[2:4]))
+}
+
+// Stats returns the statistics of all flows from the RTCP receiver reports
+// sent and the sender reports received, sorted by flow ID. They are only
+// available if ReceiverConfig.RTCPReports is set. The RTT is the RTT of the
+// transport.
+func (r *Receiver) Stats() []FlowStats {
+	rtt := r.session.Metrics().SmoothedRTT
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	stats := make([]FlowStats, 0, len(r.flows))
+	for id, flow := range r.flows {
+		s := FlowStats{FlowID: id, SSRC: flow.info.SSRC}
+		if r.stats != nil {
+			s = r.stats.get(id, flow.info.SSRC)
+		}
+		s.RTT = rtt
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].FlowID < stats[j].FlowID })
+	return stats
+}
+
+func (r *Receiver) newSink(id uint64, ssrc uint32) (MediaSink, error) {
+	if r.sinks == nil {
+		return r.onFlow(id)
+	}
+	return r.sinks.get(ssrc, func() (MediaSink, error) {
+		return r.onFlow(id)
+	})
+}
+
+func (r *Receiver) duplicates() uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.dedup == nil {
+		return 0
+	}
+	return r.dedup.duplicates
+}
+
+func (r *Receiver) handleRTCP(packet []byte) {
+	if r.rtcpReader == nil {
+		return
+	}
+	if _, _, err := r.rtcpReader.Read(packet, nil); err != nil {
+		log.Printf("rtcpReader.Read returned error: %v, dropping RTCP packet\n", err)
+		return
+	}
+	if h, ok := r.session.(rtcpHandler); ok {
+		pkts, err := rtcp.Unmarshal(packet)
+		if err != nil {
+			return
+		}
+		h.HandleRTCP(pkts)
+	}
+}
+
+func (r *Receiver) acceptStreams(ctx context.Context, st StreamTransport) {
+	for {
+		stream, err := st.AcceptUniStream(ctx)
+		if err != nil {
+			log.Printf("failed to accept stream: %v, exiting stream loop\n", err)
+			return
+		}
+		go func() {
+			if err := readStreamPackets(stream, r.handlePacket); err != nil {
+				var serr *quic.StreamError
+				if errors.As(err, &serr) && serr.ErrorCode == roqStaleFrameErrorCode {
+					return
+				}
+				log.Printf("failed to read from stream %v: %v\n", stream.StreamID(), err)
+				stream.CancelRead(0)
+			}
+		}()
 	}
 }
 
@@ -170,6 +505,8 @@ func (r *Receiver) rtcpWriter(pkts []rtcp.Packet, _ interceptor.Attributes) (int
 func (r *Receiver) Close() error {
 	defer log.Println("Receiver closed")
 	defer r.wg.Wait()
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	for _, flow := range r.flows {
 		if err := flow.media.Close(); err != nil {
 			return err
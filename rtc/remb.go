@@ -0,0 +1,441 @@
+package rtc
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// Parameters of the receive side estimator, following the delay-based
+// controller of draft-ietf-rmcat-gcc-02 with the trendline filter of
+// libwebrtc.
+const (
+	rembTrendWindow      = 20
+	rembTrendSmoothing   = 0.9
+	rembTrendGain        = 4.0
+	rembInitialThreshold = 12.5
+	rembThresholdUp      = 0.0087
+	rembThresholdDown    = 0.039
+	rembOveruseTime      = 10 * time.Millisecond
+	rembRateWindow       = 500 * time.Millisecond
+	rembIncreasePerSec   = 1.08
+	rembDecreaseFactor   = 0.85
+	rembMinBitrate       = 10_000
+
+	// rembInterval is how often REMB is sent, in addition to whenever the
+	// estimate drops by more than rembDropThreshold.
+	rembInterval      = time.Second
+	rembDropThreshold = 0.97
+)
+
+type rembUsage int
+
+const (
+	rembNormal rembUsage = iota
+	rembOveruse
+	rembUnderuse
+)
+
+func (u rembUsage) String() string {
+	switch u {
+	case rembOveruse:
+		return "overuse"
+	case rembUnderuse:
+		return "underuse"
+	}
+	return "normal"
+}
+
+// rembArrivalFilter detects overuse from the delay variation between
+// consecutive frames of one stream, using the RTP timestamp as send time.
+type rembArrivalFilter struct {
+	clockRate float64
+
+	init         bool
+	timestamp    uint32
+	arrival      time.Time
+	firstArrival time.Time
+	prevTS       uint32
+	prevArrival  time.Time
+	hasPrev      bool
+
+	accumulated float64
+	smoothed    float64
+	samples     [][2]float64
+	deltas      int
+	trend       float64
+	threshold   float64
+	lastUpdate  time.Time
+	overusing   time.Duration
+	overuses    int
+	usage       rembUsage
+}
+
+func newREMBArrivalFilter(clockRate uint32) *rembArrivalFilter {
+	return &rembArrivalFilter{
+		clockRate: float64(clockRate),
+		threshold: rembInitialThreshold,
+	}
+}
+
+// add records the arrival of a packet and updates the usage whenever a new
+// frame starts.
+func (f *rembArrivalFilter) add(now time.Time, timestamp uint32) {
+	if !f.init {
+		f.init = true
+		f.timestamp, f.arrival, f.firstArrival = timestamp, now, now
+		return
+	}
+	if timestamp == f.timestamp {
+		f.arrival = now
+		return
+	}
+	if int32(timestamp-f.timestamp) < 0 {
+		// reordered packet of an older frame
+		return
+	}
+	if f.hasPrev {
+		sendDelta := float64(int32(f.timestamp-f.prevTS)) / f.clockRate * 1000
+		arrivalDelta := float64(f.arrival.Sub(f.prevArrival).Microseconds()) / 1000
+		f.update(f.arrival, arrivalDelta-sendDelta, sendDelta)
+	}
+	f.prevTS, f.prevArrival, f.hasPrev = f.timestamp, f.arrival, true
+	f.timestamp, f.arrival = timestamp, now
+}
+
+// update runs the trendline filter and the overuse detector with the delay
+// variation delta in milliseconds.
+func (f *rembArrivalFilter) update(arrival time.Time, delta, sendDelta float64) {
+	f.deltas++
+	f.accumulated += delta
+	f.smoothed = rembTrendSmoothing*f.smoothed + (1-rembTrendSmoothing)*f.accumulated
+	f.samples = append(f.samples, [2]float64{
+		float64(arrival.Sub(f.firstArrival).Microseconds()) / 1000,
+		f.smoothed,
+	})
+	if len(f.samples) > rembTrendWindow {
+		f.samples = f.samples[1:]
+	}
+	if len(f.samples) < rembTrendWindow {
+		return
+	}
+	prevTrend := f.trend
+	f.trend = math.Min(float64(f.deltas), 60) * linearSlope(f.samples) * rembTrendGain
+
+	switch {
+	case f.trend > f.threshold:
+		f.overusing += time.Duration(sendDelta * float64(time.Millisecond))
+		f.overuses++
+		if f.overusing > rembOveruseTime && f.overuses > 1 && f.trend >= prevTrend {
+			f.overusing, f.overuses = 0, 0
+			f.usage = rembOveruse
+		}
+	case f.trend < -f.threshold:
+		f.overusing, f.overuses = 0, 0
+		f.usage = rembUnderuse
+	default:
+		f.overusing, f.overuses = 0, 0
+		f.usage = rembNormal
+	}
+
+	// adaptive threshold
+	abs := math.Abs(f.trend)
+	if !f.lastUpdate.IsZero() && abs <= f.threshold+15 {
+		k := rembThresholdUp
+		if abs < f.threshold {
+			k = rembThresholdDown
+		}
+		dt := math.Min(float64(arrival.Sub(f.lastUpdate).Milliseconds()), 100)
+		f.threshold = math.Max(6, math.Min(600, f.threshold+k*(abs-f.threshold)*dt))
+	}
+	f.lastUpdate = arrival
+}
+
+func linearSlope(samples [][2]float64) float64 {
+	var sumX, sumY float64
+	for _, s := range samples {
+		sumX += s[0]
+		sumY += s[1]
+	}
+	n := float64(len(samples))
+	meanX, meanY := sumX/n, sumY/n
+	var num, den float64
+	for _, s := range samples {
+		num += (s[0] - meanX) * (s[1] - meanY)
+		den += (s[0] - meanX) * (s[0] - meanX)
+	}
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
+
+type rembArrival struct {
+	at   time.Time
+	size int
+}
+
+// rembEstimator is a receive side bandwidth estimator. It combines the usage
+// of all streams and adapts the estimate to the incoming rate with AIMD.
+type rembEstimator struct {
+	streams  map[uint32]*rembArrivalFilter
+	arrivals []rembArrival
+	bytes    int
+
+	estimate   float64
+	increasing bool
+	lastUpdate time.Time
+	usage      rembUsage
+}
+
+func newREMBEstimator() *rembEstimator {
+	return &rembEstimator{
+		streams: map[uint32]*rembArrivalFilter{},
+	}
+}
+
+func (e *rembEstimator) add(now time.Time, header *rtp.Header, size int, clockRate uint32) {
+	f, ok := e.streams[header.SSRC]
+	if !ok {
+		f = newREMBArrivalFilter(clockRate)
+		e.streams[header.SSRC] = f
+	}
+	f.add(now, header.Timestamp)
+	e.arrivals = append(e.arrivals, rembArrival{at: now, size: size})
+	e.bytes += size
+}
+
+// incomingRate returns the rate received within the last rembRateWindow.
+func (e *rembEstimator) incomingRate(now time.Time) float64 {
+	for len(e.arrivals) > 0 && now.Sub(e.arrivals[0].at) > rembRateWindow {
+		e.bytes -= e.arrivals[0].size
+		e.arrivals = e.arrivals[1:]
+	}
+	return float64(e.bytes*8) / rembRateWindow.Seconds()
+}
+
+// update returns the new estimate, or zero while the estimator has not seen
+// enough data yet.
+func (e *rembEstimator) update(now time.Time) float64 {
+	incoming := e.incomingRate(now)
+	e.usage = rembNormal
+	for _, f := range e.streams {
+		if f.usage == rembOveruse {
+			e.usage = rembOveruse
+			break
+		}
+		if f.usage == rembUnderuse {
+			e.usage = rembUnderuse
+		}
+	}
+	if e.lastUpdate.IsZero() {
+		e.lastUpdate = now
+		return 0
+	}
+	if e.estimate == 0 {
+		if now.Sub(e.lastUpdate) < rembRateWindow {
+			return 0
+		}
+		e.estimate = incoming
+	}
+	dt := now.Sub(e.lastUpdate).Seconds()
+	e.lastUpdate = now
+
+	switch e.usage {
+	case rembOveruse:
+		e.estimate = math.Min(e.estimate, rembDecreaseFactor*incoming)
+		e.increasing = false
+	case rembUnderuse:
+		// hold, the queues are draining
+		e.increasing = false
+	default:
+		if e.increasing {
+			next := e.estimate * math.Pow(rembIncreasePerSec, dt)
+			// Don't run away from what the sender actually sends.
+			e.estimate = math.Min(math.Max(next, e.estimate+1000), 1.5*incoming+10_000)
+		}
+		e.increasing = true
+	}
+	e.estimate = math.Max(e.estimate, rembMinBitrate)
+	return e.estimate
+}
+
+type rembReceiverFactory struct{}
+
+func (f *rembReceiverFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &rembReceiver{
+		estimator: newREMBEstimator(),
+		ssrcs:     map[uint32]struct{}{},
+		ctx:       ctx,
+		cancel:    cancel,
+	}, nil
+}
+
+// rembReceiver estimates the available bandwidth from the arrival times of
+// the received packets and sends it to the sender in RTCP REMB packets.
+type rembReceiver struct {
+	interceptor.NoOp
+	lock      sync.Mutex
+	estimator *rembEstimator
+	ssrcs     map[uint32]struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func (r *rembReceiver) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	go r.loop(writer)
+	return writer
+}
+
+func (r *rembReceiver) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	clockRate := info.ClockRate
+	if clockRate == 0 {
+		clockRate = videoClockRate
+	}
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		header, err := attr.GetRTPHeader(b[:n])
+		if err != nil {
+			return 0, nil, err
+		}
+		r.lock.Lock()
+		r.estimator.add(time.Now(), header, n, clockRate)
+		r.ssrcs[header.SSRC] = struct{}{}
+		r.lock.Unlock()
+		return n, attr, nil
+	})
+}
+
+func (r *rembReceiver) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.ssrcs, info.SSRC)
+	delete(r.estimator.streams, info.SSRC)
+}
+
+func (r *rembReceiver) loop(writer interceptor.RTCPWriter) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	var lastSent time.Time
+	var lastEstimate float64
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.lock.Lock()
+			estimate := r.estimator.update(now)
+			ssrcs := make([]uint32, 0, len(r.ssrcs))
+			for ssrc := range r.ssrcs {
+				ssrcs = append(ssrcs, ssrc)
+			}
+			r.lock.Unlock()
+			if estimate == 0 || len(ssrcs) == 0 {
+				continue
+			}
+			if now.Sub(lastSent) < rembInterval && estimate > rembDropThreshold*lastEstimate {
+				continue
+			}
+			if _, err := writer.Write([]rtcp.Packet{&rtcp.ReceiverEstimatedMaximumBitrate{
+				Bitrate: float32(estimate),
+				SSRCs:   ssrcs,
+			}}, nil); err != nil {
+				log.Printf("failed to send REMB: %v\n", err)
+				continue
+			}
+			lastSent, lastEstimate = now, estimate
+		}
+	}
+}
+
+func (r *rembReceiver) Close() error {
+	r.cancel()
+	return nil
+}
+
+type rembController struct {
+	config RateControllerConfig
+}
+
+func (c *rembController) Register(r *interceptor.Registry, _ Metricer, onEstimator func(BandwidthEstimator)) error {
+	e := &rembSender{
+		target: float64(c.config.InitialBitrate),
+		config: c.config,
+	}
+	r.Add(e)
+	onEstimator(e)
+	return nil
+}
+
+// rembSender follows the bitrate of the REMB packets sent by the receiver.
+type rembSender struct {
+	interceptor.NoOp
+	config RateControllerConfig
+
+	lock     sync.Mutex
+	target   float64
+	received uint64
+	lastREMB time.Time
+}
+
+func (s *rembSender) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return s, nil
+}
+
+func (s *rembSender) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		pkts, err := attr.GetRTCPPackets(b[:n])
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, pkt := range pkts {
+			if remb, ok := pkt.(*rtcp.ReceiverEstimatedMaximumBitrate); ok {
+				s.lock.Lock()
+				s.target = math.Max(float64(s.config.MinBitrate), math.Min(float64(s.config.MaxBitrate), float64(remb.Bitrate)))
+				s.received++
+				s.lastREMB = time.Now()
+				s.lock.Unlock()
+			}
+		}
+		return n, attr, nil
+	})
+}
+
+func (s *rembSender) GetTargetBitrate() (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return int(s.target), nil
+}
+
+func (s *rembSender) GetStats() map[string]interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	age := 0.0
+	if !s.lastREMB.IsZero() {
+		age = float64(time.Since(s.lastREMB).Microseconds()) / 1000.0
+	}
+	return map[string]interface{}{
+		"rembReceived": s.received,
+		"rembAge":      age,
+	}
+}
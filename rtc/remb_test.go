@@ -0,0 +1,49 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+// feedREMB sends 30 frames per second of 10 packets with 1200 bytes each for
+// d, and updates the estimate every 100ms. Every frame is delayed by growth
+// more than the frame before.
+func feedREMB(e *rembEstimator, start time.Time, ts uint32, d, growth time.Duration) (time.Time, uint32) {
+	now := start
+	var delay time.Duration
+	for frame := 0; now.Sub(start) < d; frame++ {
+		now = start.Add(time.Duration(frame) * time.Second / 30)
+		for i := 0; i < 10; i++ {
+			e.add(now.Add(delay+time.Duration(i)*time.Millisecond), &rtp.Header{
+				SSRC:      1,
+				Timestamp: ts,
+			}, 1200, videoClockRate)
+		}
+		delay += growth
+		ts += videoClockRate / 30
+		if frame%3 == 0 {
+			e.update(now.Add(delay))
+		}
+	}
+	return now.Add(delay + time.Second/30), ts
+}
+
+func TestREMBEstimator(t *testing.T) {
+	e := newREMBEstimator()
+	start := time.Now()
+
+	// With a constant delay, the estimate grows above the incoming rate of
+	// 2.88 Mbps, but not much beyond 1.5 times of it.
+	now, ts := feedREMB(e, start, 0, 5*time.Second, 0)
+	assert.Equal(t, rembNormal, e.usage)
+	assert.Greater(t, e.estimate, 2_880_000.0)
+	assert.Less(t, e.estimate, 1.6*2_880_000)
+
+	// A growing delay is detected as overuse and the estimate drops below
+	// the incoming rate.
+	feedREMB(e, now, ts, 2*time.Second, 5*time.Millisecond)
+	assert.Less(t, e.estimate, 2_880_000.0)
+}
@@ -0,0 +1,191 @@
+package rtc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+)
+
+// FlowStats are the statistics of a flow from RTCP sender and receiver
+// reports, RFC 3550.
+type FlowStats struct {
+	FlowID uint64
+	SSRC   uint32
+
+	// Jitter is the interarrival jitter of the flow at the receiver.
+	Jitter time.Duration
+	// FractionLost is the fraction of packets lost since the previous
+	// receiver report.
+	FractionLost float64
+	// TotalLost is the cumulative number of lost packets.
+	TotalLost uint32
+	// RTT is measured from receiver reports on the sender. On the receiver,
+	// it is the RTT of the transport. Zero if unknown.
+	RTT time.Duration
+
+	// PacketsSent and OctetsSent are the counts of the last sender report.
+	PacketsSent uint32
+	OctetsSent  uint32
+	// LastReport is the time of the last report about the flow.
+	LastReport time.Time
+}
+
+// rtcpRTTAttribute is the key of the RTTs measured from the report blocks of
+// the RTCP packets read, by SSRC, in the attributes of the RTCP reader.
+type rtcpRTTAttribute struct{}
+
+// reportRTT returns the RTT of a report block which arrived at the compact NTP
+// time arrival, RFC 3550, Section 6.4.1: RTT = A - LSR - DLSR in units of
+// 1/65536s.
+func reportRTT(arrival uint32, r rtcp.ReceptionReport) (time.Duration, bool) {
+	if r.LastSenderReport == 0 {
+		return 0, false
+	}
+	rtt := arrival - r.LastSenderReport - r.Delay
+	if int32(rtt) < 0 {
+		return 0, false
+	}
+	return time.Duration(uint64(rtt) * uint64(time.Second) / 65536), true
+}
+
+func reportJitter(r rtcp.ReceptionReport) time.Duration {
+	return time.Duration(uint64(r.Jitter) * uint64(time.Second) / videoClockRate)
+}
+
+// reportStats collects FlowStats from the sender and receiver reports read
+// and written. It is the innermost interceptor of a chain, so that the
+// RTCP dumper sees the RTTs it measures.
+type reportStats struct {
+	interceptor.NoOp
+
+	lock  sync.Mutex
+	flows map[uint32]*FlowStats
+}
+
+func newReportStats() *reportStats {
+	return &reportStats{
+		flows: map[uint32]*FlowStats{},
+	}
+}
+
+// get returns the statistics of the flow with ssrc.
+func (s *reportStats) get(id uint64, ssrc uint32) FlowStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stats := FlowStats{SSRC: ssrc}
+	if f, ok := s.flows[ssrc]; ok {
+		stats = *f
+	}
+	stats.FlowID = id
+	return stats
+}
+
+func (s *reportStats) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil {
+			return 0, nil, err
+		}
+		if attr == nil {
+			attr = make(interceptor.Attributes)
+		}
+		pkts, err := attr.GetRTCPPackets(b[:n])
+		if err != nil {
+			return 0, nil, err
+		}
+		if rtts := s.handle(time.Now(), pkts, true); len(rtts) > 0 {
+			attr.Set(rtcpRTTAttribute{}, rtts)
+		}
+		return n, attr, nil
+	})
+}
+
+func (s *reportStats) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	return interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, attributes interceptor.Attributes) (int, error) {
+		s.handle(time.Now(), pkts, false)
+		return writer.Write(pkts, attributes)
+	})
+}
+
+// handle updates the statistics with the reports in pkts and returns the RTTs
+// measured from them. RTTs are only measured from received reports.
+func (s *reportStats) handle(now time.Time, pkts []rtcp.Packet, received bool) map[uint32]time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var rtts map[uint32]time.Duration
+	arrival := ntpCompact(now)
+	handleReports := func(reports []rtcp.ReceptionReport) {
+		for _, r := range reports {
+			f := s.flow(r.SSRC)
+			f.Jitter = reportJitter(r)
+			f.FractionLost = float64(r.FractionLost) / 256
+			f.TotalLost = r.TotalLost
+			f.LastReport = now
+			if !received {
+				continue
+			}
+			if rtt, ok := reportRTT(arrival, r); ok {
+				f.RTT = rtt
+				if rtts == nil {
+					rtts = map[uint32]time.Duration{}
+				}
+				rtts[r.SSRC] = rtt
+			}
+		}
+	}
+	for _, pkt := range pkts {
+		switch report := pkt.(type) {
+		case *rtcp.SenderReport:
+			f := s.flow(report.SSRC)
+			f.PacketsSent = report.PacketCount
+			f.OctetsSent = report.OctetCount
+			handleReports(report.Reports)
+		case *rtcp.ReceiverReport:
+			handleReports(report.Reports)
+		}
+	}
+	return rtts
+}
+
+// flow returns the statistics of ssrc. The caller must hold s.lock.
+func (s *reportStats) flow(ssrc uint32) *FlowStats {
+	f, ok := s.flows[ssrc]
+	if !ok {
+		f = &FlowStats{SSRC: ssrc}
+		s.flows[ssrc] = f
+	}
+	return f
+}
+
+// formatReports formats the report blocks of pkts for the RTCP dump as comma
+// separated ssrc:jitter:fraction lost:total lost:rtt entries, with jitter and
+// RTT in ms and '?' for unknown RTTs, or '-' if there are none.
+func formatReports(pkts []rtcp.Packet, rtts map[uint32]time.Duration) string {
+	var reports []rtcp.ReceptionReport
+	for _, pkt := range pkts {
+		switch report := pkt.(type) {
+		case *rtcp.SenderReport:
+			reports = append(reports, report.Reports...)
+		case *rtcp.ReceiverReport:
+			reports = append(reports, report.Reports...)
+		}
+	}
+	if len(reports) == 0 {
+		return "-"
+	}
+	sort.SliceStable(reports, func(i, j int) bool { return reports[i].SSRC < reports[j].SSRC })
+	entries := make([]string, len(reports))
+	for i, r := range reports {
+		rtt := "?"
+		if d, ok := rtts[r.SSRC]; ok {
+			rtt = fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond))
+		}
+		entries[i] = fmt.Sprintf("%v:%.1f:%.3f:%v:%v", r.SSRC, float64(reportJitter(r))/float64(time.Millisecond), float64(r.FractionLost)/256, r.TotalLost, rtt)
+	}
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,48 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportStats(t *testing.T) {
+	s := newReportStats()
+	now := time.Now()
+	report := rtcp.ReceptionReport{
+		SSRC:             1,
+		FractionLost:     64,
+		TotalLost:        10,
+		Jitter:           900,
+		LastSenderReport: ntpCompact(now.Add(-150 * time.Millisecond)),
+		Delay:            65536 / 20,
+	}
+
+	// RTTs are only measured from received reports.
+	assert.Nil(t, s.handle(now, []rtcp.Packet{&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{report}}}, false))
+	stats := s.get(7, 1)
+	assert.Equal(t, FlowStats{
+		FlowID:       7,
+		SSRC:         1,
+		Jitter:       10 * time.Millisecond,
+		FractionLost: 0.25,
+		TotalLost:    10,
+		LastReport:   now,
+	}, stats)
+
+	rtts := s.handle(now, []rtcp.Packet{
+		&rtcp.SenderReport{SSRC: 2, PacketCount: 100, OctetCount: 1000},
+		&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{report}},
+	}, true)
+	assert.InDelta(t, 100*time.Millisecond, rtts[1], float64(time.Millisecond))
+	assert.Equal(t, rtts[1], s.get(7, 1).RTT)
+	assert.Equal(t, uint32(100), s.get(8, 2).PacketsSent)
+	assert.Equal(t, FlowStats{FlowID: 9, SSRC: 3}, s.get(9, 3))
+
+	assert.Equal(t, "1:10.0:0.250:10:100.0", formatReports([]rtcp.Packet{
+		&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{report}},
+	}, map[uint32]time.Duration{1: 100 * time.Millisecond}))
+	assert.Equal(t, "-", formatReports([]rtcp.Packet{&rtcp.PictureLossIndication{}}, nil))
+}
@@ -0,0 +1,209 @@
+package rtc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+	"github.com/pion/rtp"
+)
+
+// RoQMapping selects how RTP packets are mapped onto QUIC, see
+// draft-ietf-avtcore-rtp-over-quic.
+type RoQMapping int
+
+const (
+	// DatagramMapping sends every RTP packet in a QUIC DATAGRAM prefixed by
+	// the flow ID.
+	DatagramMapping RoQMapping = iota
+	// StreamPerFrameMapping opens a new unidirectional stream for every
+	// media frame (all packets sharing an RTP timestamp).
+	StreamPerFrameMapping
+	// StreamPerFlowMapping sends all packets of a flow on a single
+	// long-lived unidirectional stream.
+	StreamPerFlowMapping
+)
+
+func ParseRoQMapping(s string) (RoQMapping, error) {
+	switch s {
+	case "datagram":
+		return DatagramMapping, nil
+	case "stream-per-frame":
+		return StreamPerFrameMapping, nil
+	case "stream-per-flow":
+		return StreamPerFlowMapping, nil
+	}
+	return 0, fmt.Errorf("unknown RoQ mapping: %v", s)
+}
+
+func (m RoQMapping) String() string {
+	switch m {
+	case DatagramMapping:
+		return "datagram"
+	case StreamPerFrameMapping:
+		return "stream-per-frame"
+	case StreamPerFlowMapping:
+		return "stream-per-flow"
+	}
+	return fmt.Sprintf("RoQMapping(%d)", int(m))
+}
+
+// StreamTransport is implemented by transports which can carry RTP on
+// unidirectional streams in addition to datagrams.
+type StreamTransport interface {
+	OpenUniStreamSync(context.Context) (quic.SendStream, error)
+	AcceptUniStream(context.Context) (quic.ReceiveStream, error)
+}
+
+// roqStaleFrameErrorCode is used to reset streams carrying frames which are
+// too old to be useful for the receiver.
+const roqStaleFrameErrorCode = quic.StreamErrorCode(0x01)
+
+var errStreamsNotSupported = errors.New("transport does not support streams")
+
+func varintBytes(i uint64) []byte {
+	var buf bytes.Buffer
+	quicvarint.Write(quicvarint.NewWriter(&buf), i)
+	return buf.Bytes()
+}
+
+// appendLengthPrefixed appends pkt to buf, prefixed by its length as a QUIC
+// varint.
+func appendLengthPrefixed(buf, pkt []byte) []byte {
+	buf = append(buf, varintBytes(uint64(len(pkt)))...)
+	return append(buf, pkt...)
+}
+
+// readStreamPackets reads a flow ID followed by a sequence of length prefixed
+// RTP packets from r and calls handle for every packet. It returns nil when
+// the stream was closed cleanly.
+func readStreamPackets(r io.Reader, handle func(uint64, []byte)) error {
+	reader := quicvarint.NewReader(r)
+	id, err := quicvarint.Read(reader)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return fmt.Errorf("failed to read flow ID: %w", err)
+	}
+	for {
+		length, err := quicvarint.Read(reader)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read packet length: %w", err)
+		}
+		if length == 0 {
+			continue
+		}
+		pkt := make([]byte, length)
+		if _, err := io.ReadFull(reader, pkt); err != nil {
+			return fmt.Errorf("failed to read packet: %w", err)
+		}
+		handle(id, pkt)
+	}
+}
+
+type frameStream struct {
+	stream quic.SendStream
+	opened time.Time
+}
+
+// streamWriter writes the RTP packets of one flow to QUIC streams.
+type streamWriter struct {
+	ctx        context.Context
+	transport  StreamTransport
+	idBytes    []byte
+	perFrame   bool
+	staleAfter time.Duration
+
+	current   *frameStream
+	timestamp uint32
+	closed    []*frameStream
+}
+
+func newStreamWriter(ctx context.Context, transport StreamTransport, id uint64, mapping RoQMapping, staleAfter time.Duration) *streamWriter {
+	return &streamWriter{
+		ctx:        ctx,
+		transport:  transport,
+		idBytes:    varintBytes(id),
+		perFrame:   mapping == StreamPerFrameMapping,
+		staleAfter: staleAfter,
+	}
+}
+
+func (w *streamWriter) write(header *rtp.Header, pkt []byte) error {
+	if w.current != nil && w.perFrame && header.Timestamp != w.timestamp {
+		w.finishFrame()
+	}
+	if w.current == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+		w.timestamp = header.Timestamp
+	}
+	if _, err := w.current.stream.Write(appendLengthPrefixed(nil, pkt)); err != nil {
+		return err
+	}
+	if w.perFrame && header.Marker {
+		w.finishFrame()
+	}
+	return nil
+}
+
+func (w *streamWriter) open() error {
+	w.resetStale()
+	stream, err := w.transport.OpenUniStreamSync(w.ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := stream.Write(w.idBytes); err != nil {
+		return err
+	}
+	w.current = &frameStream{
+		stream: stream,
+		opened: time.Now(),
+	}
+	return nil
+}
+
+func (w *streamWriter) finishFrame() {
+	if err := w.current.stream.Close(); err != nil {
+		log.Printf("failed to close stream: %v\n", err)
+	}
+	if w.staleAfter > 0 {
+		w.closed = append(w.closed, w.current)
+	}
+	w.current = nil
+}
+
+// resetStale resets all finished frame streams which are older than
+// staleAfter, so that QUIC stops retransmitting them.
+func (w *streamWriter) resetStale() {
+	if w.staleAfter == 0 {
+		return
+	}
+	now := time.Now()
+	i := 0
+	for ; i < len(w.closed); i++ {
+		if now.Sub(w.closed[i].opened) < w.staleAfter {
+			break
+		}
+		w.closed[i].stream.CancelWrite(roqStaleFrameErrorCode)
+	}
+	w.closed = w.closed[i:]
+}
+
+func (w *streamWriter) Close() error {
+	if w.current == nil {
+		return nil
+	}
+	return w.current.stream.Close()
+}
@@ -0,0 +1,70 @@
+package rtc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadStreamPackets(t *testing.T) {
+	cases := []struct {
+		id      uint64
+		packets [][]byte
+	}{
+		{
+			id:      0,
+			packets: [][]byte{},
+		},
+		{
+			id:      1,
+			packets: [][]byte{{1, 2, 3}},
+		},
+		{
+			id:      1000,
+			packets: [][]byte{{1}, bytes.Repeat([]byte{2}, 1200), {3, 4}},
+		},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			stream := varintBytes(tc.id)
+			for _, pkt := range tc.packets {
+				stream = appendLengthPrefixed(stream, pkt)
+			}
+			result := [][]byte{}
+			err := readStreamPackets(bytes.NewReader(stream), func(id uint64, pkt []byte) {
+				assert.Equal(t, tc.id, id)
+				result = append(result, pkt)
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.packets, result)
+		})
+	}
+}
+
+func TestReadStreamPacketsTruncated(t *testing.T) {
+	stream := appendLengthPrefixed(varintBytes(3), []byte{1, 2, 3, 4})
+	err := readStreamPackets(bytes.NewReader(stream[:len(stream)-1]), func(uint64, []byte) {
+		t.Fatal("unexpected packet")
+	})
+	assert.Error(t, err)
+}
+
+// The data of --stream reads as a flow without packets.
+func TestReadStreamPacketsZeros(t *testing.T) {
+	err := readStreamPackets(bytes.NewReader(make([]byte, 1200)), func(uint64, []byte) {
+		t.Fatal("unexpected packet")
+	})
+	assert.NoError(t, err)
+}
+
+func TestParseRoQMapping(t *testing.T) {
+	for _, m := range []RoQMapping{DatagramMapping, StreamPerFrameMapping, StreamPerFlowMapping} {
+		parsed, err := ParseRoQMapping(m.String())
+		assert.NoError(t, err)
+		assert.Equal(t, m, parsed)
+	}
+	_, err := ParseRoQMapping("invalid")
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+package rtc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// RTCPTracker estimates RTT and loss from the report blocks of RTCP sender
+// and receiver reports. It provides metrics for transports which can't
+// measure them themselves. The RTT can only be measured by the peer which
+// sends sender reports.
+type RTCPTracker struct {
+	lock   sync.Mutex
+	stats  RTTStats
+	losses map[uint32]*reportedLoss
+}
+
+// reportedLoss is the loss of a single SSRC from its latest report block.
+type reportedLoss struct {
+	fraction float64
+	total    uint32
+	highest  uint32
+	// expected is the number of packets expected in the interval of the
+	// latest report, zero if unknown.
+	expected uint32
+}
+
+func NewRTCPTracker() *RTCPTracker {
+	return &RTCPTracker{
+		losses: map[uint32]*reportedLoss{},
+	}
+}
+
+func (t *RTCPTracker) Metrics() RTTStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.stats
+}
+
+// HandleRTCP updates the metrics with the report blocks contained in pkts.
+func (t *RTCPTracker) HandleRTCP(pkts []rtcp.Packet) {
+	t.handleRTCP(time.Now(), pkts)
+}
+
+func (t *RTCPTracker) handleRTCP(now time.Time, pkts []rtcp.Packet) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	arrival := ntpCompact(now)
+	for _, pkt := range pkts {
+		switch report := pkt.(type) {
+		case *rtcp.SenderReport:
+			t.handleReports(arrival, report.Reports)
+		case *rtcp.ReceiverReport:
+			t.handleReports(arrival, report.Reports)
+		}
+	}
+}
+
+func (t *RTCPTracker) handleReports(arrival uint32, reports []rtcp.ReceptionReport) {
+	for _, r := range reports {
+		l, ok := t.losses[r.SSRC]
+		if !ok {
+			l = &reportedLoss{}
+			t.losses[r.SSRC] = l
+		} else {
+			l.expected = r.LastSequenceNumber - l.highest
+		}
+		l.fraction = float64(r.FractionLost) / 256
+		l.total = r.TotalLost
+		l.highest = r.LastSequenceNumber
+		if rtt, ok := reportRTT(arrival, r); ok {
+			t.addRTTSample(rtt)
+		}
+	}
+	if len(reports) > 0 {
+		t.aggregateLosses()
+	}
+}
+
+// aggregateLosses sums the losses of all SSRCs and weights their loss rates by
+// the number of packets expected in the interval of their latest reports.
+func (t *RTCPTracker) aggregateLosses() {
+	var lost uint64
+	var rate, weights float64
+	for _, l := range t.losses {
+		lost += uint64(l.total)
+		w := float64(l.expected)
+		if w == 0 {
+			w = 1
+		}
+		rate += w * l.fraction
+		weights += w
+	}
+	t.stats.Lost = lost
+	t.stats.LossRate = rate / weights
+}
+
+// addRTTSample smoothes RTT samples as described in RFC 6298.
+func (t *RTCPTracker) addRTTSample(rtt time.Duration) {
+	t.stats.LatestRTT = rtt
+	if t.stats.MinRTT == 0 || rtt < t.stats.MinRTT {
+		t.stats.MinRTT = rtt
+	}
+	if t.stats.SmoothedRTT == 0 {
+		t.stats.SmoothedRTT = rtt
+		t.stats.RTTVar = rtt / 2
+		return
+	}
+	delta := t.stats.SmoothedRTT - rtt
+	if delta < 0 {
+		delta = -delta
+	}
+	t.stats.RTTVar = (3*t.stats.RTTVar + delta) / 4
+	t.stats.SmoothedRTT = (7*t.stats.SmoothedRTT + rtt) / 8
+}
+
+// ntpTime converts t to a 64 bit NTP timestamp.
+func ntpTime(t time.Time) uint64 {
+	s := float64(t.UnixNano())/1e9 + 2208988800
+	integerPart := uint32(s)
+	fractionalPart := uint32((s - float64(integerPart)) * 0xFFFFFFFF)
+	return uint64(integerPart)<<32 | uint64(fractionalPart)
+}
+
+// ntpCompact returns the middle 32 bits of the NTP timestamp of t as used in
+// the LSR field of reception reports.
+func ntpCompact(t time.Time) uint32 {
+	return uint32(ntpTime(t) >> 16)
+}
+
+// isRTCP distinguishes RTCP from RTP packets as described in RFC 5761,
+// Section 4.
+func isRTCP(pkt []byte) bool {
+	return len(pkt) >= 2 && pkt[1] >= 192 && pkt[1] <= 223
+}
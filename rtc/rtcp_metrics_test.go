@@ -0,0 +1,88 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRTCPTracker(t *testing.T) {
+	now := time.Now()
+	tracker := NewRTCPTracker()
+
+	tracker.handleRTCP(now, []rtcp.Packet{
+		&rtcp.ReceiverReport{
+			Reports: []rtcp.ReceptionReport{{
+				FractionLost:     64,
+				TotalLost:        10,
+				LastSenderReport: ntpCompact(now.Add(-150 * time.Millisecond)),
+				Delay:            65536 / 20,
+			}},
+		},
+	})
+	stats := tracker.Metrics()
+	assert.InDelta(t, 100*time.Millisecond, stats.LatestRTT, float64(time.Millisecond))
+	assert.Equal(t, stats.LatestRTT, stats.SmoothedRTT)
+	assert.Equal(t, stats.LatestRTT, stats.MinRTT)
+	assert.Equal(t, stats.LatestRTT/2, stats.RTTVar)
+	assert.Equal(t, 0.25, stats.LossRate)
+	assert.Equal(t, uint64(10), stats.Lost)
+
+	tracker.handleRTCP(now, []rtcp.Packet{
+		&rtcp.SenderReport{
+			Reports: []rtcp.ReceptionReport{{
+				LastSenderReport: ntpCompact(now.Add(-200 * time.Millisecond)),
+			}},
+		},
+	})
+	stats = tracker.Metrics()
+	assert.InDelta(t, 200*time.Millisecond, stats.LatestRTT, float64(time.Millisecond))
+	assert.InDelta(t, 100*time.Millisecond, stats.MinRTT, float64(time.Millisecond))
+	assert.InDelta(t, 112500*time.Microsecond, stats.SmoothedRTT, float64(time.Millisecond))
+}
+
+func TestRTCPTrackerAggregatesLoss(t *testing.T) {
+	tracker := NewRTCPTracker()
+	tracker.handleRTCP(time.Now(), []rtcp.Packet{
+		&rtcp.ReceiverReport{
+			Reports: []rtcp.ReceptionReport{
+				{SSRC: 1, TotalLost: 10, LastSequenceNumber: 100},
+				{SSRC: 2, TotalLost: 5, LastSequenceNumber: 100},
+			},
+		},
+	})
+	// The loss rates are weighted by the packets expected since the
+	// previous report.
+	tracker.handleRTCP(time.Now(), []rtcp.Packet{
+		&rtcp.ReceiverReport{
+			Reports: []rtcp.ReceptionReport{{SSRC: 1, FractionLost: 128, TotalLost: 60, LastSequenceNumber: 200}},
+		},
+		&rtcp.ReceiverReport{
+			Reports: []rtcp.ReceptionReport{{SSRC: 2, TotalLost: 5, LastSequenceNumber: 400}},
+		},
+	})
+	stats := tracker.Metrics()
+	assert.Equal(t, uint64(65), stats.Lost)
+	assert.Equal(t, 0.125, stats.LossRate)
+}
+
+func TestRTCPTrackerIgnoresMissingLSR(t *testing.T) {
+	tracker := NewRTCPTracker()
+	tracker.handleRTCP(time.Now(), []rtcp.Packet{
+		&rtcp.ReceiverReport{
+			Reports: []rtcp.ReceptionReport{{}},
+		},
+	})
+	assert.Equal(t, RTTStats{}, tracker.Metrics())
+}
+
+func TestIsRTCP(t *testing.T) {
+	sr, err := (&rtcp.SenderReport{}).Marshal()
+	assert.NoError(t, err)
+	assert.True(t, isRTCP(sr))
+	assert.False(t, isRTCP([]byte{0x80, 96}))
+	assert.False(t, isRTCP([]byte{0x80, 96 | 0x80}))
+	assert.False(t, isRTCP([]byte{0x80}))
+}
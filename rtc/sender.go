@@ -3,6 +3,7 @@ package rtc
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -13,289 +14,653 @@ import (
 
 	quic "github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/quicvarint"
-	screamcgo "github.com/mengelbart/scream-go"
 	"github.com/pion/interceptor"
-	"github.com/pion/interceptor/pkg/cc"
-	"github.com/pion/interceptor/scream/pkg/scream"
+	"github.com/pion/interceptor/pkg/twcc"
+	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 )
 
 const transportCCURI = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
 
-type SenderFactory func(MediaSource) (*Sender, error)
+// transportCCExtensionID is the ID of the transport-wide sequence number
+// header extension of all flows.
+const transportCCExtensionID = 1
+
+// localTWCCInterval is how often local TWCC feedback is generated, like the
+// default interval of the TWCC interceptor.
+const localTWCCInterval = 100 * time.Millisecond
+
+// videoClockRate is the RTP clock rate of all supported video codecs.
+const videoClockRate = 90000
+
+type SenderFactory func() (*Sender, error)
 
 type MediaSource interface {
 	io.Reader
 	SetBitRate(uint)
+	// ForceKeyframe makes the encoder send a keyframe next, in response to
+	// a PLI or FIR of the receiver. Sources which can't return
+	// ErrKeyframeUnsupported.
+	ForceKeyframe() error
 }
 
 type sendFlow struct {
-	media  io.Reader
+	id      uint64
+	media   MediaSource
+	config  FlowConfig
+	info    *interceptor.StreamInfo
+	removed chan struct{}
+	// lastKeyframe is when a keyframe was forced last. Guarded by
+	// Sender.lock.
+	lastKeyframe time.Time
+
+	// writer and stream are bound to the current session, they are nil while
+	// the Sender is disconnected. Guarded by Sender.lock.
 	writer interceptor.RTPWriter
+	stream *streamWriter
 }
 
+// Dialer opens a new connection to the receiver.
+type Dialer func() (Transport, error)
+
+const (
+	reconnectMinBackoff = 100 * time.Millisecond
+	reconnectMaxBackoff = 5 * time.Second
+)
+
 type Sender struct {
-	session     Transport
-	flows       map[uint64]*sendFlow
+	ctx        context.Context
+	rc         *bitrateAllocator
+	newSession func(context.Context, Transport) (*senderSession, error)
+	redial     Dialer
+
+	mapping          RoQMapping
+	streamResetAfter time.Duration
+
+	lock    sync.Mutex
+	flows   map[uint64]*sendFlow
+	session *senderSession
+	running bool
+	errCh   chan error
+	// dropped counts the packets read while disconnected.
+	dropped uint64
+	// firSeqs holds the sequence number of the last FIR per SSRC.
+	firSeqs map[uint32]uint8
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// senderSession holds the state of a Sender which is bound to a single
+// connection to the receiver.
+type senderSession struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	transport   Transport
 	interceptor interceptor.Interceptor
+	ackCallback func(ackedPkt)
+	// stats collects the statistics of the flows from RTCP reports.
+	stats *reportStats
 
 	// additional locally generated rtcp reports channel
 	reports chan []byte
 
-	done chan struct{}
-	wg   sync.WaitGroup
+	failed    chan error
+	closeOnce sync.Once
+}
+
+// fail reports that the connection broke. A nil error means that the peer
+// closed the connection.
+func (s *senderSession) fail(err error) {
+	select {
+	case s.failed <- err:
+	default:
+	}
+}
+
+func (s *senderSession) close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.cancel()
+		err = s.interceptor.Close()
+		if cerr := s.transport.CloseWithError(0, "eos"); err == nil {
+			err = cerr
+		}
+	})
+	return err
 }
 
 type SenderConfig struct {
 	RTPDump        io.Writer
 	RTCPDump       io.Writer
 	CCDump         io.Writer
-	SCReAM         bool
-	GCC            bool
 	LocalRFC8888   bool
 	InitialBitrate uint
+	// LocalTWCC generates TWCC feedback from the acknowledgments of the
+	// transport, so that GCC runs without feedback from the receiver.
+	LocalTWCC bool
+	// RateController adapts the bitrate of the media sources. If nil, they
+	// keep their initial bitrate.
+	RateController RateController
+	// RateControlInterval is how often the target bitrate is applied,
+	// DefaultRateControlInterval if zero.
+	RateControlInterval time.Duration
+	// RateControlOnFeedback additionally applies the target bitrate
+	// whenever RTCP feedback arrives.
+	RateControlOnFeedback bool
+	// RTCPReports enables RTCP sender reports. UDP transports use the
+	// receiver reports sent in response to measure the RTT.
+	RTCPReports bool
+	// RTX retransmits packets the receiver NACKs, unless they would arrive
+	// later than RTXPlayoutDelay, DefaultRTXPlayoutDelay if zero, after
+	// the original packet.
+	RTX             bool
+	RTXPlayoutDelay time.Duration
+	// FEC protects the flows with FlexFEC packets. FECOverhead is the
+	// number of FEC packets per media packet, adapted to the loss rate if
+	// zero. The FEC bitrate is subtracted from the bitrate of the media
+	// sources.
+	FEC         bool
+	FECOverhead float64
+
+	// Mapping of RTP packets onto QUIC, only used by QUIC transports.
+	Mapping RoQMapping
+	// StreamResetAfter is the age after which streams of finished frames are
+	// reset when using StreamPerFrameMapping. Zero disables resetting.
+	StreamResetAfter time.Duration
+
+	// SRTP enables SRTP and SRTCP, see SRTPTransport.
+	SRTP *SRTPConfig
+
+	// Redial is used to reconnect when the connection breaks. If nil, the
+	// Sender stops instead.
+	Redial Dialer
 }
 
-type rateController struct {
-	pipelines []MediaSource
-}
-
-func (c *rateController) addPipeline(p MediaSource) {
-	c.pipelines = append(c.pipelines, p)
-}
-
-func (c *rateController) screamLoopFactory(ctx context.Context, file io.Writer) scream.NewPeerConnectionCallback {
-	return func(_ string, bwe scream.BandwidthEstimator) {
-		go func() {
-			ticker := time.NewTicker(200 * time.Millisecond)
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case now := <-ticker.C:
-					target, err := bwe.GetTargetBitrate(0)
-					if err != nil {
-						log.Printf("failed to get target bitrate: %v\n", err)
-					}
-					if target < 0 {
-						if target == -1 {
-							log.Println("scream detected a loss")
-						} else {
-							log.Printf("got negative target bitrate: %v\n", target)
-						}
-						continue
-					}
-					stats := bwe.GetStats()
-					fmt.Fprintf(
-						file, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
-						now.Format(time.RFC3339Nano),
-						target,
-						stats["queueDelay"],
-						stats["sRTT"],
-						stats["cwnd"],
-						stats["bytesInFlightLog"],
-						stats["rateLostStream0"],
-						stats["rateTransmittedStream0"],
-						stats["rateAckedStream0"],
-						stats["hiSeqAckStream0"],
-					)
-					if len(c.pipelines) == 0 {
-						continue
-					}
-					share := target / len(c.pipelines)
-					for _, p := range c.pipelines {
-						p.SetBitRate(uint(share))
-					}
+func GstreamerSenderFactory(ctx context.Context, c SenderConfig, session Transport) (SenderFactory, error) {
+	rc := bitrateAllocator{
+		target: int(c.InitialBitrate),
+	}
+	interval := c.RateControlInterval
+	if interval == 0 {
+		interval = DefaultRateControlInterval
+	}
+	// Every session gets a new interceptor chain, so that the congestion
+	// controller starts from scratch after reconnecting.
+	newInterceptor := func(ctx context.Context, t Transport, stats *reportStats) (interceptor.Interceptor, error) {
+		ir := interceptor.Registry{}
+		if err := registerRTPSenderDumper(&ir, c.RTPDump, c.RTCPDump); err != nil {
+			return nil, err
+		}
+		if c.FEC {
+			// Registered before the rate controller, so that FEC
+			// packets bypass its pacer, and before RTX, which keeps
+			// only media packets.
+			if err := registerFEC(&ir, t, c.FECOverhead, rc.setFECOverhead); err != nil {
+				return nil, err
+			}
+		}
+		if c.RTX {
+			// Registered before the rate controller, so that
+			// retransmissions bypass its pacer.
+			if err := registerRTX(&ir, t, c.RTXPlayoutDelay); err != nil {
+				return nil, err
+			}
+		}
+		var notifier *feedbackNotifier
+		if c.RateController != nil {
+			if c.RateControlOnFeedback {
+				notifier = newFeedbackNotifier()
+			}
+			if err := c.RateController.Register(&ir, t, func(bwe BandwidthEstimator) {
+				var feedback <-chan struct{}
+				if notifier != nil {
+					feedback = notifier.feedback
 				}
+				go rc.run(ctx, bwe, interval, feedback, c.CCDump)
+			}); err != nil {
+				return nil, err
 			}
-		}()
+		}
+		if c.RTCPReports {
+			if err := registerSenderReports(&ir); err != nil {
+				return nil, err
+			}
+		}
+		if notifier != nil {
+			// Registered last, so that it notifies after the rate
+			// controller has read the feedback.
+			ir.Add(notifier)
+		}
+		i, err := ir.Build("")
+		if err != nil {
+			return nil, err
+		}
+		return interceptor.NewChain([]interceptor.Interceptor{stats, i}), nil
 	}
-}
 
-func (c *rateController) gccLoopFactory(ctx context.Context, file io.Writer) cc.NewPeerConnectionCallback {
-	return func(_ string, bwe cc.BandwidthEstimator) {
-		go func() {
-			ticker := time.NewTicker(200 * time.Millisecond)
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case now := <-ticker.C:
-					target := bwe.GetTargetBitrate()
-					if target < 0 {
-						log.Printf("got negative target bitrate: %v\n", target)
-						continue
-					}
-					stats := bwe.GetStats()
-					fmt.Fprintf(file, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
-						now.Format(time.RFC3339Nano),
-						target,
-						stats["lossTargetBitrate"],
-						stats["averageLoss"],
-						stats["delayTargetBitrate"],
-						stats["delayMeasurement"],
-						stats["delayEstimate"],
-						stats["delayThreshold"],
-						stats["rtt"],
-						stats["usage"],
-						stats["state"])
-					if len(c.pipelines) == 0 {
-						continue
-					}
-					share := target / len(c.pipelines)
-					for _, p := range c.pipelines {
-						p.SetBitRate(uint(share))
+	newSession := func(ctx context.Context, t Transport) (*senderSession, error) {
+		var err error
+		if c.SRTP != nil {
+			t, err = NewSRTPTransport(t, true, *c.SRTP)
+			if err != nil {
+				return nil, err
+			}
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		stats := newReportStats()
+		i, err := newInterceptor(ctx, t, stats)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		sess := &senderSession{
+			ctx:         ctx,
+			cancel:      cancel,
+			transport:   t,
+			interceptor: i,
+			stats:       stats,
+			failed:      make(chan error, 1),
+		}
+		var acks []chan ackedPkt
+		if c.LocalRFC8888 || c.LocalTWCC {
+			sess.reports = make(chan []byte)
+		}
+		if c.LocalRFC8888 {
+			rfc8888Acks := make(chan ackedPkt)
+			acks = append(acks, rfc8888Acks)
+			go newLocalRFC8888Generator(t, sess.reports, rfc8888Acks).Run(ctx)
+		}
+		if c.LocalTWCC {
+			twccAcks := make(chan ackedPkt)
+			acks = append(acks, twccAcks)
+			go newLocalTWCCGenerator(t, sess.reports, twccAcks).Run(ctx)
+		}
+		if len(acks) > 0 {
+			sess.ackCallback = func(a ackedPkt) {
+				for _, ch := range acks {
+					select {
+					case ch <- a:
+					case <-ctx.Done():
+						return
 					}
 				}
 			}
-		}()
+		}
+		return sess, nil
 	}
+
+	return func() (*Sender, error) {
+		return newSender(ctx, session, newSession, &rc, c)
+	}, nil
 }
 
-func GstreamerSenderFactory(ctx context.Context, c SenderConfig, session Transport) (SenderFactory, error) {
-	ir := interceptor.Registry{}
-	if err := registerRTPSenderDumper(&ir, c.RTPDump, c.RTCPDump); err != nil {
+func newSender(ctx context.Context, session Transport, newSession func(context.Context, Transport) (*senderSession, error), rc *bitrateAllocator, c SenderConfig) (*Sender, error) {
+	s := &Sender{
+		ctx:              ctx,
+		rc:               rc,
+		newSession:       newSession,
+		redial:           c.Redial,
+		flows:            map[uint64]*sendFlow{},
+		firSeqs:          map[uint32]uint8{},
+		mapping:          c.Mapping,
+		streamResetAfter: c.StreamResetAfter,
+		errCh:            make(chan error, 1),
+		done:             make(chan struct{}),
+		wg:               sync.WaitGroup{},
+	}
+	if err := s.startSession(session); err != nil {
 		return nil, err
 	}
-	var rc rateController
-	if c.SCReAM {
-		if err := registerSCReAM(&ir, c.InitialBitrate, rc.screamLoopFactory(ctx, c.CCDump)); err != nil {
-			return nil, err
+	return s, nil
+}
+
+// startSession binds the RTCP reader and writer and all flows to a new
+// session on t.
+func (s *Sender) startSession(t Transport) error {
+	sess, err := s.newSession(s.ctx, t)
+	if err != nil {
+		t.CloseWithError(0, "eos")
+		return err
+	}
+	rtcpReader := sess.interceptor.BindRTCPReader(interceptor.RTCPReaderFunc(func(in []byte, _ interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return len(in), nil, nil
+	}))
+	_ = sess.interceptor.BindRTCPWriter(s.rtcpWriter(sess))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, flow := range s.flows {
+		if err := s.bindFlow(sess, flow); err != nil {
+			for _, f := range s.flows {
+				s.releaseFlow(sess, f)
+			}
+			sess.close()
+			return err
 		}
 	}
-	if c.GCC {
-		if err := registerGCC(&ir, c.InitialBitrate, rc.gccLoopFactory(ctx, c.CCDump)); err != nil {
-			return nil, err
+	s.session = sess
+	go s.readRTCP(sess, rtcpReader)
+	return nil
+}
+
+// endSession releases all flows from sess and closes it.
+func (s *Sender) endSession(sess *senderSession) error {
+	s.lock.Lock()
+	for _, flow := range s.flows {
+		s.releaseFlow(sess, flow)
+	}
+	if s.session == sess {
+		s.session = nil
+	}
+	s.lock.Unlock()
+	return sess.close()
+}
+
+// reconnect redials with exponential backoff until it succeeds or the Sender
+// is closed, in which case it returns nil.
+func (s *Sender) reconnect() *senderSession {
+	start := time.Now()
+	backoff := reconnectMinBackoff
+	for {
+		t, err := s.redial()
+		if err == nil {
+			if err = s.startSession(t); err == nil {
+				break
+			}
 		}
-		if err := registerTWCCHeaderExtension(&ir); err != nil {
-			return nil, err
+		log.Printf("failed to reconnect: %v, retrying in %v\n", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-s.done:
+			return nil
+		}
+		backoff *= 2
+		if backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
 		}
 	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	log.Printf("reconnected after %v disconnected, dropped %v packets\n", time.Since(start), s.dropped)
+	s.dropped = 0
+	return s.session
+}
 
-	interceptor, err := ir.Build("")
-	if err != nil {
-		return nil, err
-	}
+// ssrcSource is implemented by media sources which know the SSRC of the RTP
+// packets they produce.
+type ssrcSource interface {
+	SSRC() uint
+}
 
-	return func(src MediaSource) (*Sender, error) {
-		rc.addPipeline(src)
+// AddFlow adds a new flow which sends the RTP packets read from src. Flows can
+// be added before and while the Sender is running.
+func (s *Sender) AddFlow(id uint64, src MediaSource) error {
+	return s.AddFlowWithConfig(id, src, FlowConfig{})
+}
 
-		var ackCallback func(ackedPkt)
-		var reports chan []byte
-		if c.LocalRFC8888 {
-			acks := make(chan ackedPkt)
-			ackCallback = func(a ackedPkt) {
-				acks <- a
-			}
-			reports = make(chan []byte)
-			fbGenerator := newLocalRFC8888Generator(screamcgo.NewRx(0), session, reports, acks)
-			go fbGenerator.Run(ctx)
+// AddFlowWithConfig is like AddFlow, c sets the share of the flow in the
+// target bitrate.
+func (s *Sender) AddFlowWithConfig(id uint64, src MediaSource, c FlowConfig) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.flows[id]; ok {
+		return fmt.Errorf("flow %v already exists", id)
+	}
+	flow := newFlow(id, src)
+	flow.config = c
+	if s.session != nil {
+		if err := s.bindFlow(s.session, flow); err != nil {
+			return err
 		}
+	}
+	s.flows[id] = flow
+	s.rc.addPipeline(src, c)
+	if s.running {
+		s.startFlow(flow)
+	}
+	return nil
+}
 
-		sender, err := newSender(session, interceptor, reports)
-		if err != nil {
-			return nil, err
+// RemoveFlow stops sending the flow with the given ID. The caller remains
+// responsible for closing the flow's MediaSource.
+func (s *Sender) RemoveFlow(id uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	flow, ok := s.flows[id]
+	if !ok {
+		return fmt.Errorf("unknown flow ID: %v", id)
+	}
+	delete(s.flows, id)
+	s.rc.removePipeline(flow.media)
+	close(flow.removed)
+	if !s.running {
+		s.releaseFlow(s.session, flow)
+	}
+	return nil
+}
+
+// Stats returns the statistics of all flows from the RTCP receiver reports of
+// the current connection, sorted by flow ID. They are only available if
+// SenderConfig.RTCPReports is set and the receiver sends receiver reports.
+func (s *Sender) Stats() []FlowStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stats := make([]FlowStats, 0, len(s.flows))
+	for id, flow := range s.flows {
+		if s.session == nil {
+			stats = append(stats, FlowStats{FlowID: id, SSRC: flow.info.SSRC})
+			continue
 		}
-		// TODO: This should be done somewhere else, where it is less static
-		sender.setFlow(0, src, ackCallback)
-		return sender, nil
-	}, nil
+		stats = append(stats, s.session.stats.get(id, flow.info.SSRC))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].FlowID < stats[j].FlowID })
+	return stats
 }
 
-func newSender(session Transport, interceptor interceptor.Interceptor, reports chan []byte) (*Sender, error) {
-	return &Sender{
-		session:     session,
-		flows:       map[uint64]*sendFlow{},
-		interceptor: interceptor,
-		reports:     reports,
-		done:        make(chan struct{}),
-		wg:          sync.WaitGroup{},
-	}, nil
+func newFlow(id uint64, src MediaSource) *sendFlow {
+	var ssrc uint32
+	if ss, ok := src.(ssrcSource); ok {
+		ssrc = uint32(ss.SSRC())
+	}
+	return &sendFlow{
+		id:      id,
+		media:   src,
+		removed: make(chan struct{}),
+		info: &interceptor.StreamInfo{
+			ID:                  "",
+			Attributes:          map[interface{}]interface{}{},
+			SSRC:                ssrc,
+			PayloadType:         0,
+			RTPHeaderExtensions: []interceptor.RTPHeaderExtension{{URI: transportCCURI, ID: transportCCExtensionID}},
+			MimeType:            "",
+			ClockRate:           videoClockRate,
+			Channels:            0,
+			SDPFmtpLine:         "",
+			RTCPFeedback:        []interceptor.RTCPFeedback{{Type: "ack", Parameter: "ccfb"}},
+		},
+	}
 }
 
-func (s *Sender) setFlow(id uint64, pipeline io.Reader, ackCallback func(ackedPkt)) {
-	streamWriter := s.interceptor.BindLocalStream(&interceptor.StreamInfo{
-		ID:                  "",
-		Attributes:          map[interface{}]interface{}{},
-		SSRC:                0,
-		PayloadType:         0,
-		RTPHeaderExtensions: []interceptor.RTPHeaderExtension{{URI: transportCCURI, ID: 1}},
-		MimeType:            "",
-		ClockRate:           0,
-		Channels:            0,
-		SDPFmtpLine:         "",
-		RTCPFeedback:        []interceptor.RTCPFeedback{{Type: "ack", Parameter: "ccfb"}},
-	}, s.getRTPWriter(id, ackCallback))
+// bindFlow binds flow to the interceptor of sess. The caller must hold
+// s.lock.
+func (s *Sender) bindFlow(sess *senderSession, flow *sendFlow) error {
+	maxWeight := weight(flow.config)
+	for _, f := range s.flows {
+		if w := weight(f.config); w > maxWeight {
+			maxWeight = w
+		}
+	}
+	setSCReAMAttributes(flow.info.Attributes, flow.config, maxWeight)
+	rtpWriter := s.getRTPWriter(sess, flow.id)
+	if s.mapping != DatagramMapping {
+		st, ok := sess.transport.(StreamTransport)
+		if !ok {
+			return errStreamsNotSupported
+		}
+		flow.stream = newStreamWriter(s.ctx, st, flow.id, s.mapping, s.streamResetAfter)
+		rtpWriter = s.getStreamRTPWriter(sess, flow.stream)
+	}
+	flow.writer = sess.interceptor.BindLocalStream(flow.info, rtpWriter)
+	return nil
+}
 
-	s.flows[id] = &sendFlow{
-		media:  pipeline,
-		writer: streamWriter,
+// releaseFlow unbinds flow from sess. The caller must hold s.lock.
+func (s *Sender) releaseFlow(sess *senderSession, flow *sendFlow) {
+	if flow.writer == nil {
+		return
+	}
+	sess.interceptor.UnbindLocalStream(flow.info)
+	flow.writer = nil
+	if flow.stream != nil {
+		if err := flow.stream.Close(); err != nil {
+			log.Printf("failed to close stream of flow %v: %v\n", flow.id, err)
+		}
+		flow.stream = nil
 	}
 }
 
+// Run sends the flows until the Sender is closed or the connection breaks.
+// If SenderConfig.Redial is set, Run reconnects instead and keeps reading the
+// media sources while disconnected, so that their RTP sequence numbers and
+// timestamps continue.
 func (s *Sender) Run() (err error) {
 	s.wg.Add(1)
 	defer s.wg.Done()
 
-	rtcpReader := s.interceptor.BindRTCPReader(interceptor.RTCPReaderFunc(func(in []byte, _ interceptor.Attributes) (int, interceptor.Attributes, error) {
-		return len(in), nil, nil
-	}))
+	s.lock.Lock()
+	s.running = true
+	for _, flow := range s.flows {
+		s.startFlow(flow)
+	}
+	sess := s.session
+	s.lock.Unlock()
+
+	for {
+		select {
+		case <-s.done:
+			return nil
+		case err := <-s.errCh:
+			return err
+		case err := <-sess.failed:
+			if s.redial == nil {
+				return err
+			}
+			log.Printf("connection lost: %v, reconnecting\n", err)
+			if err := s.endSession(sess); err != nil {
+				log.Printf("failed to close session: %v\n", err)
+			}
+			if sess = s.reconnect(); sess == nil {
+				return nil
+			}
+		}
+	}
+}
+
+// startFlow reads the flow's media in a new goroutine, so that a slow source
+// does not stall the other flows.
+func (s *Sender) startFlow(flow *sendFlow) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.runFlow(flow); err != nil {
+			select {
+			case s.errCh <- err:
+			default:
+			}
+		}
+	}()
+}
 
-	go s.readRTCP(rtcpReader, s.reports)
+func (s *Sender) removeFlow(flow *sendFlow) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.releaseFlow(s.session, flow)
+}
 
+func (s *Sender) runFlow(flow *sendFlow) error {
 	buf := make([]byte, 1200)
 	for {
 		select {
 		case <-s.done:
 			return nil
+		case <-flow.removed:
+			s.removeFlow(flow)
+			return nil
 		default:
-			for _, flow := range s.flows {
-				n, err := flow.media.Read(buf)
-				if err != nil {
-					return err
-				}
-				//log.Printf("%v bytes read from pipeline\n", n)
-				var pkt rtp.Packet
-				err = pkt.Unmarshal(buf[:n])
-				if err != nil {
-					return err
-				}
-				_, err = flow.writer.Write(&pkt.Header, pkt.Payload, nil)
-				if err != nil {
-					if errors.Is(errConnectionClosed, err) {
-						return nil
-					}
-					return err
-				}
+		}
+		n, err := flow.media.Read(buf)
+		if err != nil {
+			select {
+			case <-flow.removed:
+				s.removeFlow(flow)
+				return nil
+			default:
 			}
-			//log.Printf("%v bytes written to connection\n", n)
+			return err
+		}
+		//log.Printf("%v bytes read from pipeline\n", n)
+		var pkt rtp.Packet
+		err = pkt.Unmarshal(buf[:n])
+		if err != nil {
+			return err
+		}
+		s.lock.Lock()
+		writer, sess := flow.writer, s.session
+		if writer == nil {
+			s.dropped++
+		}
+		s.lock.Unlock()
+		if writer == nil {
+			continue
 		}
+		_, err = writer.Write(&pkt.Header, pkt.Payload, nil)
+		if err != nil {
+			if errors.Is(errConnectionClosed, err) {
+				return nil
+			}
+			if errors.Is(err, errMigrating) {
+				continue
+			}
+			sess.fail(err)
+		}
+		//log.Printf("%v bytes written to connection\n", n)
 	}
 }
 
-func (s *Sender) readRTCP(rtcpReader interceptor.RTCPReader, localReports chan []byte) {
+func (s *Sender) readRTCP(sess *senderSession, rtcpReader interceptor.RTCPReader) {
 	networkReports := make(chan []byte)
 
 	receiveFeedbackFunc := func() {
-		buf, err := s.session.ReceiveMessage()
+		buf, err := sess.transport.ReceiveMessage()
 		if err != nil {
 			if qerr, ok := err.(*quic.ApplicationError); ok && qerr.ErrorCode == 0 {
 				log.Printf("connection closed, exiting")
+				sess.fail(nil)
 				return
 			}
 			log.Printf("session.ReceiveMessage returned error: %v, exiting RTCP reader\n", err)
+			sess.fail(err)
 			return
 		}
-		networkReports <- buf
+		select {
+		case networkReports <- buf:
+		case <-sess.ctx.Done():
+		}
 	}
 	go receiveFeedbackFunc()
 
 	for {
 		var report []byte
 		select {
-		case report = <-localReports:
+		case report = <-sess.reports:
 		case report = <-networkReports:
 			go receiveFeedbackFunc()
+			s.handleNetworkRTCP(sess, report)
+		case <-sess.ctx.Done():
+			return
 		}
 
 		if _, _, err := rtcpReader.Read(report, nil); err != nil {
@@ -305,9 +670,94 @@ func (s *Sender) readRTCP(rtcpReader interceptor.RTCPReader, localReports chan [
 	}
 }
 
+// rtcpHandler is implemented by transports which derive metrics from RTCP,
+// see RTCPTracker.
+type rtcpHandler interface {
+	HandleRTCP([]rtcp.Packet)
+}
+
+func (s *Sender) handleNetworkRTCP(sess *senderSession, report []byte) {
+	pkts, err := rtcp.Unmarshal(report)
+	if err != nil {
+		log.Printf("failed to unmarshal RTCP: %v\n", err)
+		return
+	}
+	s.forceKeyframes(pkts)
+	if h, ok := sess.transport.(rtcpHandler); ok {
+		h.HandleRTCP(pkts)
+	}
+}
+
+// forceKeyframes forces a keyframe on the sources of the flows pkts ask
+// keyframes for, at most once per minKeyframeInterval per flow.
+func (s *Sender) forceKeyframes(pkts []rtcp.Packet) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, ssrc := range keyframeRequests(pkts, s.firSeqs) {
+		var flow *sendFlow
+		for _, f := range s.flows {
+			if f.info.SSRC == ssrc {
+				flow = f
+			}
+		}
+		if flow == nil {
+			log.Printf("got keyframe request for unknown SSRC %v\n", ssrc)
+			continue
+		}
+		now := time.Now()
+		if since := now.Sub(flow.lastKeyframe); since < minKeyframeInterval {
+			log.Printf("flow %v: keyframe requested, ignored since the last one was forced %v ago\n", flow.id, since)
+			continue
+		}
+		if err := flow.media.ForceKeyframe(); err != nil {
+			log.Printf("flow %v: keyframe requested, failed to force keyframe: %v\n", flow.id, err)
+			continue
+		}
+		flow.lastKeyframe = now
+		log.Printf("flow %v: keyframe requested, forced keyframe\n", flow.id)
+	}
+}
+
+// rtcpWriter sends RTCP packets generated by the interceptors. Like RTP
+// packets, they are prefixed by the flow ID and multiplexed as described in
+// RFC 5761.
+func (s *Sender) rtcpWriter(sess *senderSession) interceptor.RTCPWriter {
+	return interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, _ interceptor.Attributes) (int, error) {
+		if s.isClosed() {
+			return 0, errConnectionClosed
+		}
+		buf, err := rtcp.Marshal(pkts)
+		if err != nil {
+			return 0, err
+		}
+		msg := append(varintBytes(s.rtcpFlowID(pkts)), buf...)
+		return len(buf), sess.transport.SendMessage(msg, nil, nil)
+	})
+}
+
+// rtcpFlowID returns the ID of the flow whose SSRC sends pkts.
+func (s *Sender) rtcpFlowID(pkts []rtcp.Packet) uint64 {
+	if len(pkts) == 0 {
+		return 0
+	}
+	sr, ok := pkts[0].(*rtcp.SenderReport)
+	if !ok {
+		return 0
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for id, flow := range s.flows {
+		if flow.info.SSRC == sr.SSRC {
+			return id
+		}
+	}
+	return 0
+}
+
 var errConnectionClosed = errors.New("connection closed")
 
-func (s *Sender) getRTPWriter(id uint64, ackCallback func(ackedPkt)) interceptor.RTPWriter {
+func (s *Sender) getRTPWriter(sess *senderSession, id uint64) interceptor.RTPWriter {
+	ackCallback := sess.ackCallback
 	var buf bytes.Buffer
 	idWriter := quicvarint.NewWriter(&buf)
 	quicvarint.Write(idWriter, id)
@@ -330,28 +780,40 @@ func (s *Sender) getRTPWriter(id uint64, ackCallback func(ackedPkt)) interceptor
 		seqNr := header.SequenceNumber
 		ssrc := header.SSRC
 		ts := time.Now()
+		var twccSeqNr uint16
+		ext := header.GetExtension(transportCCExtensionID)
+		hasTWCC := len(ext) >= 2
+		if hasTWCC {
+			twccSeqNr = binary.BigEndian.Uint16(ext)
+		}
 
 		// log.Printf("Sending RTP #%v\n", seqNr)
 
-		if err := s.session.SendMessage(buf, nil, func(b bool) {
+		if err := sess.transport.SendMessage(buf, nil, func(b bool) {
 			if ackCallback == nil {
 				return
 			}
 			if b {
 				go ackCallback(ackedPkt{
-					sentTS: ts,
-					ssrc:   ssrc,
-					size:   size,
-					seqNr:  seqNr,
+					sentTS:    ts,
+					ssrc:      ssrc,
+					size:      size,
+					seqNr:     seqNr,
+					twccSeqNr: twccSeqNr,
+					hasTWCC:   hasTWCC,
 				})
 			}
 		}); err != nil {
-			s.close()
 			if qerr, ok := err.(*quic.ApplicationError); ok && qerr.ErrorCode == 0 {
 				log.Printf("connection closed by remote")
+				sess.fail(nil)
 				return 0, errConnectionClosed
 			}
+			if errors.Is(err, errMigrating) {
+				return 0, err
+			}
 			log.Printf("failed to sendMessage: %v, closing\n", err)
+			sess.fail(err)
 			return 0, err
 		}
 		//log.Printf("%v bytes written to connection\n", len(dgramBuffer))
@@ -359,6 +821,26 @@ func (s *Sender) getRTPWriter(id uint64, ackCallback func(ackedPkt)) interceptor
 	})
 }
 
+func (s *Sender) getStreamRTPWriter(sess *senderSession, w *streamWriter) interceptor.RTPWriter {
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+		if s.isClosed() {
+			return 0, errConnectionClosed
+		}
+		headerBuf, err := header.Marshal()
+		if err != nil {
+			log.Printf("failed to marshal header: %v\n", err)
+			return 0, err
+		}
+		pkt := append(headerBuf, payload...)
+		if err := w.write(header, pkt); err != nil {
+			sess.fail(err)
+			log.Printf("failed to write to stream: %v, closing\n", err)
+			return 0, err
+		}
+		return len(pkt), nil
+	})
+}
+
 func (s *Sender) close() {
 	if !s.isClosed() {
 		close(s.done)
@@ -375,7 +857,14 @@ func (s *Sender) isClosed() bool {
 }
 
 func (s *Sender) Close() error {
+	s.lock.Lock()
+	flows := make([]*sendFlow, 0, len(s.flows))
 	for _, flow := range s.flows {
+		flows = append(flows, flow)
+	}
+	s.lock.Unlock()
+
+	for _, flow := range flows {
 		go func(f *sendFlow) {
 			if _, err := io.ReadAll(f.media); err != nil {
 				panic(err)
@@ -384,13 +873,14 @@ func (s *Sender) Close() error {
 	}
 	s.close()
 	s.wg.Wait()
-	if err := s.interceptor.Close(); err != nil {
-		return err
-	}
-	if err := s.session.CloseWithError(0, "eos"); err != nil {
-		return err
+
+	s.lock.Lock()
+	sess := s.session
+	s.lock.Unlock()
+	if sess == nil {
+		return nil
 	}
-	return nil
+	return s.endSession(sess)
 }
 
 type ackedPkt struct {
@@ -398,79 +888,112 @@ type ackedPkt struct {
 	ssrc   uint32
 	size   int
 	seqNr  uint16
-}
-
-func getNTPT0() float64 {
-	now := time.Now()
-	secs := now.Unix()
-	usecs := now.UnixMicro() - secs*1e6
-	return (float64(secs) + float64(usecs)*1e-6) - 1e-3
-}
-
-func getTimeBetweenNTP(t0 float64, tx time.Time) uint64 {
-	secs := tx.Unix()
-	usecs := tx.UnixMicro() - secs*1e6
-	tt := (float64(secs) + float64(usecs)*1e-6) - t0
-	ntp64 := uint64(tt * 65536.0)
-	ntp := 0xFFFFFFFF & ntp64
-	return ntp
+	// twccSeqNr is the transport-wide sequence number, only valid if
+	// hasTWCC is set.
+	twccSeqNr uint16
+	hasTWCC   bool
 }
 
 type Metricer interface {
 	Metrics() RTTStats
 }
 
+// localRFC8888Generator generates RFC 8888 feedback from the acknowledgments
+// of the transport, assuming that packets arrive after half the RTT.
 type localRFC8888Generator struct {
-	rx        *screamcgo.Rx
+	generator *ccfbGenerator
 	m         Metricer
 	report    chan<- []byte
 	ackedPkts <-chan ackedPkt
-	t0        float64
 }
 
-func newLocalRFC8888Generator(rx *screamcgo.Rx, m Metricer, report chan<- []byte, acks <-chan ackedPkt) *localRFC8888Generator {
+func newLocalRFC8888Generator(m Metricer, report chan<- []byte, acks <-chan ackedPkt) *localRFC8888Generator {
 	return &localRFC8888Generator{
-		rx:        rx,
+		generator: newCCFBGenerator(CCFBDraft),
 		m:         m,
 		report:    report,
 		ackedPkts: acks,
-		t0:        getNTPT0(),
 	}
 }
 
-func (f *localRFC8888Generator) ntpTime(t time.Time) uint64 {
-	return getTimeBetweenNTP(f.t0, t)
+func (f *localRFC8888Generator) Run(ctx context.Context) {
+	t := time.NewTicker(DefaultCCFBInterval)
+	defer t.Stop()
+	for {
+		select {
+		case pkt := <-f.ackedPkts:
+			arrival := pkt.sentTS.Add(f.m.Metrics().LatestRTT / 2)
+			f.generator.add(pkt.ssrc, pkt.seqNr, arrival, 0)
+
+		case now := <-t.C:
+			for _, fb := range f.generator.feedback(now) {
+				buf, err := fb.Marshal()
+				if err != nil {
+					log.Printf("failed to marshal local RFC 8888 feedback: %v\n", err)
+					continue
+				}
+				select {
+				case f.report <- buf:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
-func (f *localRFC8888Generator) Run(ctx context.Context) {
-	t := time.NewTicker(10 * time.Millisecond)
-	var buf []ackedPkt
+// localTWCCGenerator generates TWCC feedback from the acknowledgments of the
+// transport, assuming that packets arrive after half the RTT.
+type localTWCCGenerator struct {
+	recorder  *twcc.Recorder
+	m         Metricer
+	report    chan<- []byte
+	ackedPkts <-chan ackedPkt
+	start     time.Time
+}
+
+func newLocalTWCCGenerator(m Metricer, report chan<- []byte, acks <-chan ackedPkt) *localTWCCGenerator {
+	return &localTWCCGenerator{
+		recorder:  twcc.NewRecorder(0),
+		m:         m,
+		report:    report,
+		ackedPkts: acks,
+		start:     time.Now(),
+	}
+}
+
+func (f *localTWCCGenerator) Run(ctx context.Context) {
+	t := time.NewTicker(localTWCCInterval)
+	defer t.Stop()
+	recorded := 0
 	for {
 		select {
 		case pkt := <-f.ackedPkts:
-			buf = append(buf, pkt)
+			if !pkt.hasTWCC {
+				continue
+			}
+			arrival := pkt.sentTS.Add(f.m.Metrics().LatestRTT / 2)
+			f.recorder.Record(pkt.ssrc, pkt.twccSeqNr, arrival.Sub(f.start).Microseconds())
+			recorded++
 
 		case <-t.C:
-			if len(buf) == 0 {
+			// The recorder drops single packets instead of reporting them.
+			if recorded < 2 {
 				continue
 			}
-			sort.Slice(buf, func(i, j int) bool {
-				return buf[i].seqNr < buf[j].seqNr
-			})
-
-			metrics := f.m.Metrics()
-
-			var lastTS uint64
-			for _, pkt := range buf {
-				sent := f.ntpTime(pkt.sentTS)
-				rttNTP := metrics.LatestRTT.Seconds() * 65536
-				lastTS = sent + uint64(rttNTP)/2
-				f.rx.Receive(lastTS, pkt.ssrc, pkt.size, pkt.seqNr, 0)
+			recorded = 0
+			buf, err := rtcp.Marshal(f.recorder.BuildFeedbackPacket())
+			if err != nil {
+				log.Printf("failed to marshal local TWCC feedback: %v\n", err)
+				continue
 			}
-			buf = []ackedPkt{}
-
-			if ok, fb := f.rx.CreateStandardizedFeedback(lastTS, true); ok {
-				f.report <- fb
+			select {
+			case f.report <- buf:
+			case <-ctx.Done():
+				return
 			}
 
 		case <-ctx.Done():
@@ -484,4 +1007,16 @@ type RTTStats struct {
 	SmoothedRTT time.Duration
 	RTTVar      time.Duration
 	LatestRTT   time.Duration
+
+	// Cwnd is the congestion window in bytes, zero if unknown.
+	Cwnd uint64
+	// BytesInFlight is the number of sent but unacknowledged bytes counted
+	// against Cwnd.
+	BytesInFlight uint64
+	// Lost is the cumulative number of lost packets. For TCP, it is the
+	// number of retransmitted segments.
+	Lost uint64
+	// LossRate is the most recently reported fraction of lost packets, zero
+	// if unknown.
+	LossRate float64
 }
@@ -2,6 +2,7 @@ package rtc
 
 import (
 	"context"
+	"crypto/tls"
 	"log"
 	"sync"
 	"time"
@@ -12,32 +13,46 @@ import (
 
 type ReceiverFactory func(Transport, MediaSinkFactory) (*Receiver, error)
 
-type MediaSinkFactory func() (MediaSink, error)
+// MediaSinkFactory creates the MediaSink for the flow with the given ID.
+type MediaSinkFactory func(flowID uint64) (MediaSink, error)
+
+// maxIncomingUniStreams allows a sender using StreamPerFrameMapping to keep
+// enough frames in flight.
+const maxIncomingUniStreams = 1000
 
 type Server struct {
-	wg           sync.WaitGroup
-	listener     quic.Listener
-	makeReceiver ReceiverFactory
-	sinkFactory  MediaSinkFactory
+	wg              sync.WaitGroup
+	listener        quic.EarlyListener
+	acceptEarlyData bool
+	makeReceiver    ReceiverFactory
+	sinkFactory     MediaSinkFactory
 }
 
-func NewServer(f ReceiverFactory, addr string, sinkFactory MediaSinkFactory, tracer logging.Tracer) (*Server, error) {
+// NewServer creates a QUIC server. If tlsConf is nil, it uses a self-signed
+// certificate, see NewServerTLSConfig. Clients can resume sessions using
+// session tickets and send 0-RTT data.
+func NewServer(f ReceiverFactory, addr string, sinkFactory MediaSinkFactory, tracer logging.Tracer, tlsConf *tls.Config) (*Server, error) {
 	quicConf := &quic.Config{
-		EnableDatagrams:      true,
-		HandshakeIdleTimeout: 15 * time.Second,
-		Tracer:               tracer,
+		EnableDatagrams:       true,
+		HandshakeIdleTimeout:  15 * time.Second,
+		MaxIncomingUniStreams: maxIncomingUniStreams,
+		Tracer:                tracer,
 	}
 
-	listener, err := quic.ListenAddr(addr, generateTLSConfig(), quicConf)
+	if tlsConf == nil {
+		tlsConf = generateTLSConfig()
+	}
+	listener, err := quic.ListenAddrEarly(addr, tlsConf, quicConf)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Server{
-		wg:           sync.WaitGroup{},
-		listener:     listener,
-		makeReceiver: f,
-		sinkFactory:  sinkFactory,
+		wg:              sync.WaitGroup{},
+		listener:        listener,
+		acceptEarlyData: tlsConf.ClientAuth == tls.NoClientCert,
+		makeReceiver:    f,
+		sinkFactory:     sinkFactory,
 	}, nil
 }
 
@@ -54,24 +69,33 @@ func (s *Server) Listen(ctx context.Context) (err error) {
 		if err != nil {
 			return err
 		}
-		go s.receiveStreamLoop(ctx, session)
+		go s.handleSession(ctx, session, time.Now())
+	}
+}
 
-		receiver, err := s.makeReceiver(&QUICTransport{
-			RTTTracer: nil,
-			Session:   session,
-		}, s.sinkFactory)
-		if err != nil {
-			log.Printf("failed to create receiver: %v\n", err)
-			continue
-		}
-		go func() {
-			log.Println("starting receiver")
-			defer receiver.Close()
-			err := receiver.run(ctx)
-			if err != nil {
-				log.Printf("receiver closed connection: %v\n", err)
-			}
-		}()
+// handleSession runs a Receiver on session. Data that arrives in 0-RTT packets
+// is passed to the Receiver before the handshake completes, unless clients
+// have to authenticate, since their certificate is only verified at the end of
+// the handshake.
+func (s *Server) handleSession(ctx context.Context, session quic.EarlySession, accepted time.Time) {
+	if s.acceptEarlyData {
+		go WaitHandshake(session, accepted)
+	} else if err := WaitHandshake(session, accepted); err != nil {
+		log.Printf("%v, dropping session\n", err)
+		return
+	}
+	receiver, err := s.makeReceiver(&QUICTransport{
+		RTTTracer: nil,
+		Session:   session,
+	}, s.sinkFactory)
+	if err != nil {
+		log.Printf("failed to create receiver: %v\n", err)
+		return
+	}
+	log.Println("starting receiver")
+	defer receiver.Close()
+	if err := receiver.run(ctx); err != nil {
+		log.Printf("receiver closed connection: %v\n", err)
 	}
 }
 
@@ -84,24 +108,6 @@ func (t *QUICTransport) CloseWithError(code int, msg string) error {
 	return t.Session.CloseWithError(quic.ApplicationErrorCode(code), msg)
 }
 
-func (s *Server) receiveStreamLoop(ctx context.Context, session quic.Session) error {
-	log.Println("Accept stream")
-	defer log.Println("Exiting receive stream loop")
-	stream, err := session.AcceptUniStream(ctx)
-	if err != nil {
-		return err
-	}
-	log.Println("got stream")
-	buf := make([]byte, 1200)
-	for {
-		_, err := stream.Read(buf)
-		if err != nil {
-			return err
-		}
-		//log.Printf("received %v stream bytes\n", n)
-	}
-}
-
 func (s *Server) Close() error {
 	defer log.Println("Receiver closed")
 	defer s.wg.Wait()
@@ -0,0 +1,87 @@
+package rtc
+
+import (
+	"log"
+	"sync"
+)
+
+// SinkPool shares MediaSinks between the Receivers of a server, so that a
+// sender which reconnects continues writing to the sinks of its previous
+// connection. Sinks are identified by the SSRC of their flow, which a
+// reconnecting sender keeps. Sinks stay open until the pool is closed.
+type SinkPool struct {
+	lock   sync.Mutex
+	sinks  map[uint32]*pooledSink
+	closed bool
+}
+
+func NewSinkPool() *SinkPool {
+	return &SinkPool{
+		sinks: map[uint32]*pooledSink{},
+	}
+}
+
+// get returns the sink for ssrc and creates it using create if the pool
+// doesn't have one yet.
+func (p *SinkPool) get(ssrc uint32, create func() (MediaSink, error)) (MediaSink, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		return create()
+	}
+	if sink, ok := p.sinks[ssrc]; ok {
+		log.Printf("resuming sink of SSRC %v\n", ssrc)
+		return sink, nil
+	}
+	media, err := create()
+	if err != nil {
+		return nil, err
+	}
+	sink := &pooledSink{media: media}
+	p.sinks[ssrc] = sink
+	return sink, nil
+}
+
+// Close closes all sinks in the pool.
+func (p *SinkPool) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.closed = true
+	var err error
+	for ssrc, sink := range p.sinks {
+		if cerr := sink.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(p.sinks, ssrc)
+	}
+	return err
+}
+
+type pooledSink struct {
+	lock   sync.Mutex
+	media  MediaSink
+	closed bool
+}
+
+func (s *pooledSink) Write(b []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return len(b), nil
+	}
+	return s.media.Write(b)
+}
+
+// Close leaves the sink open for the next connection, see SinkPool.Close.
+func (s *pooledSink) Close() error {
+	return nil
+}
+
+func (s *pooledSink) close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return s.media.Close()
+}
@@ -0,0 +1,48 @@
+package rtc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bufferSink struct {
+	bytes.Buffer
+	closed int
+}
+
+func (s *bufferSink) Close() error {
+	s.closed++
+	return nil
+}
+
+func TestSinkPool(t *testing.T) {
+	pool := NewSinkPool()
+	media := &bufferSink{}
+	created := 0
+	create := func() (MediaSink, error) {
+		created++
+		return media, nil
+	}
+
+	sink, err := pool.get(1, create)
+	assert.NoError(t, err)
+	_, err = sink.Write([]byte("a"))
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Close())
+	assert.Equal(t, 0, media.closed)
+
+	sink, err = pool.get(1, create)
+	assert.NoError(t, err)
+	_, err = sink.Write([]byte("b"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+	assert.Equal(t, "ab", media.String())
+
+	assert.NoError(t, pool.Close())
+	assert.Equal(t, 1, media.closed)
+	_, err = sink.Write([]byte("c"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", media.String())
+}
@@ -0,0 +1,436 @@
+package rtc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/quicvarint"
+	"github.com/pion/dtls/v2"
+	"github.com/pion/rtcp"
+	"github.com/pion/srtp/v2"
+	"github.com/pion/transport/deadline"
+)
+
+// Every message of an SRTPTransport starts with one of the following types.
+// The type byte is needed because flow IDs can't be told apart from DTLS
+// records as described in RFC 7983.
+const (
+	srtpMessage byte = iota
+	dtlsMessage
+)
+
+const (
+	srtpProfile          = srtp.ProtectionProfileAes128CmHmacSha1_80
+	srtpKeyLen           = 16
+	srtpSaltLen          = 14
+	dtlsHandshakeTimeout = 15 * time.Second
+)
+
+// SRTPConfig configures the SRTP and SRTCP protection of a Transport.
+type SRTPConfig struct {
+	// Key is the pre-shared master key followed by the master salt, see
+	// ParseSRTPKey. Each direction of each session uses its own master key
+	// and salt derived from Key and the nonces of the session, see
+	// sessionNoncer. If Key is empty, the keys are negotiated in a DTLS-SRTP
+	// handshake (RFC 5764) instead.
+	Key []byte
+
+	// DTLS selects the certificates and the verification of the peer in the
+	// DTLS-SRTP handshake, see NewClientTLSConfig and NewServerTLSConfig. The
+	// sender must verify the receiver's certificate by a CA or pins.
+	DTLS *tls.Config
+}
+
+var (
+	errUnverifiedDTLS = errors.New("DTLS-SRTP requires verifying the receiver's certificate by a CA or pins")
+	errNoSessionNonce = errors.New("pre-shared SRTP keys require a transport which exchanges session nonces")
+)
+
+// sessionNoncer is implemented by transports which exchange random nonces
+// when opening a session. SSRCs, sequence numbers and SRTCP indices start
+// over in every session, so the keys derived from a pre-shared key must
+// differ to not reuse the keystream of an earlier session.
+type sessionNoncer interface {
+	SessionNonce() []byte
+}
+
+// ParseSRTPKey parses a hex encoded master key and salt for
+// AES_CM_128_HMAC_SHA1_80.
+func ParseSRTPKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRTP key: %w", err)
+	}
+	if len(key) != srtpKeyLen+srtpSaltLen {
+		return nil, fmt.Errorf("invalid SRTP key length: got %v bytes, expected %v", len(key), srtpKeyLen+srtpSaltLen)
+	}
+	return key, nil
+}
+
+// SRTPTransport encrypts RTP and RTCP sent over the wrapped Transport using
+// SRTP and SRTCP. Messages sent by a Sender are prefixed with a flow ID which
+// stays in cleartext, messages sent by a Receiver aren't. With DTLS-SRTP, the
+// Sender acts as the DTLS client. Sending blocks until the handshake is done.
+type SRTPTransport struct {
+	Transport
+	sender bool
+
+	ready   chan struct{}
+	keysErr error
+
+	localLock sync.Mutex
+	local     *srtp.Context
+	remote    *srtp.Context
+
+	dtls    *dtlsConn
+	in      chan []byte
+	readErr error
+}
+
+// NewSRTPTransport wraps t. sender must be true on the side of the Sender.
+func NewSRTPTransport(t Transport, sender bool, c SRTPConfig) (*SRTPTransport, error) {
+	s := &SRTPTransport{
+		Transport: t,
+		sender:    sender,
+		ready:     make(chan struct{}),
+		in:        make(chan []byte, 1000),
+	}
+	if len(c.Key) > 0 {
+		if len(c.Key) != srtpKeyLen+srtpSaltLen {
+			return nil, fmt.Errorf("invalid SRTP key length: %v", len(c.Key))
+		}
+		n, ok := t.(sessionNoncer)
+		if !ok {
+			return nil, errNoSessionNonce
+		}
+		if err := s.setKeys(deriveSRTPKeys(c.Key, n.SessionNonce(), sender)); err != nil {
+			return nil, err
+		}
+		close(s.ready)
+	} else {
+		if c.DTLS == nil {
+			return nil, errors.New("DTLS-SRTP without TLS config")
+		}
+		if sender && c.DTLS.InsecureSkipVerify && c.DTLS.VerifyPeerCertificate == nil {
+			return nil, errUnverifiedDTLS
+		}
+		s.dtls = newDTLSConn(t)
+		go s.handshake(c.DTLS)
+	}
+	go s.readLoop()
+	return s, nil
+}
+
+// deriveSRTPKeys derives the master keys and salts of both directions from
+// the pre-shared key and the nonce of the session, so that neither the two
+// directions nor two sessions ever share a keystream.
+func deriveSRTPKeys(psk, nonce []byte, sender bool) srtp.SessionKeys {
+	derive := func(label string) []byte {
+		mac := hmac.New(sha256.New, psk)
+		mac.Write([]byte(label))
+		mac.Write(nonce)
+		return mac.Sum(nil)
+	}
+	toReceiver, toSender := derive("sender to receiver"), derive("receiver to sender")
+	if !sender {
+		toReceiver, toSender = toSender, toReceiver
+	}
+	return srtp.SessionKeys{
+		LocalMasterKey:   toReceiver[:srtpKeyLen],
+		LocalMasterSalt:  toReceiver[srtpKeyLen : srtpKeyLen+srtpSaltLen],
+		RemoteMasterKey:  toSender[:srtpKeyLen],
+		RemoteMasterSalt: toSender[srtpKeyLen : srtpKeyLen+srtpSaltLen],
+	}
+}
+
+func (s *SRTPTransport) setKeys(keys srtp.SessionKeys) error {
+	var err error
+	s.local, err = srtp.CreateContext(keys.LocalMasterKey, keys.LocalMasterSalt, srtpProfile)
+	if err != nil {
+		return err
+	}
+	s.remote, err = srtp.CreateContext(keys.RemoteMasterKey, keys.RemoteMasterSalt, srtpProfile, srtp.SRTPReplayProtection(64), srtp.SRTCPReplayProtection(64))
+	return err
+}
+
+// handshake negotiates the SRTP keys in a DTLS handshake, which fails if the
+// peer's certificate does not pass the verification of tlsConf.
+func (s *SRTPTransport) handshake(tlsConf *tls.Config) {
+	defer close(s.ready)
+
+	config := &dtls.Config{
+		Certificates:           tlsConf.Certificates,
+		SRTPProtectionProfiles: []dtls.SRTPProtectionProfile{dtls.SRTP_AES128_CM_HMAC_SHA1_80},
+		ExtendedMasterSecret:   dtls.RequireExtendedMasterSecret,
+		// The client authentication types of crypto/tls and DTLS match.
+		ClientAuth:            dtls.ClientAuthType(tlsConf.ClientAuth),
+		InsecureSkipVerify:    tlsConf.InsecureSkipVerify,
+		VerifyPeerCertificate: tlsConf.VerifyPeerCertificate,
+		RootCAs:               tlsConf.RootCAs,
+		ClientCAs:             tlsConf.ClientCAs,
+		ServerName:            tlsConf.ServerName,
+		ConnectContextMaker: func() (context.Context, func()) {
+			return context.WithTimeout(context.Background(), dtlsHandshakeTimeout)
+		},
+	}
+	var conn *dtls.Conn
+	var err error
+	if s.sender {
+		conn, err = dtls.Client(s.dtls, config)
+	} else {
+		conn, err = dtls.Server(s.dtls, config)
+	}
+	if err != nil {
+		s.keysErr = fmt.Errorf("DTLS handshake failed: %w", err)
+		return
+	}
+	state := conn.ConnectionState()
+	srtpConfig := srtp.Config{Profile: srtpProfile}
+	if err = srtpConfig.ExtractSessionKeysFromDTLS(&state, s.sender); err != nil {
+		s.keysErr = err
+		return
+	}
+	if err = s.setKeys(srtpConfig.Keys); err != nil {
+		s.keysErr = err
+		return
+	}
+	log.Println("DTLS-SRTP handshake done")
+
+	// Keep reading to answer retransmissions of the peer's last flight and to
+	// notice close_notify alerts.
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func (s *SRTPTransport) keysReady() error {
+	<-s.ready
+	return s.keysErr
+}
+
+func (s *SRTPTransport) readLoop() {
+	defer close(s.in)
+	for {
+		msg, err := s.Transport.ReceiveMessage()
+		if err != nil {
+			s.readErr = err
+			if s.dtls != nil {
+				s.dtls.Close()
+			}
+			return
+		}
+		if len(msg) == 0 {
+			continue
+		}
+		switch msg[0] {
+		case dtlsMessage:
+			if s.dtls != nil {
+				s.dtls.push(msg[1:])
+			}
+		case srtpMessage:
+			// The peer only sends SRTP once it has completed the handshake, so
+			// our side doesn't need any more records to complete it either.
+			if err := s.keysReady(); err != nil {
+				continue
+			}
+			plain, err := s.unprotect(msg[1:])
+			if err != nil {
+				log.Printf("failed to decrypt SRTP message: %v, dropping message\n", err)
+				continue
+			}
+			s.in <- plain
+		default:
+			log.Printf("got SRTP transport message of unknown type %v, dropping message\n", msg[0])
+		}
+	}
+}
+
+// flowIDLen returns the length of the flow ID prefix of msg.
+func flowIDLen(msg []byte) (int, error) {
+	id, err := quicvarint.Read(bytes.NewReader(msg))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read flow ID: %w", err)
+	}
+	return int(quicvarint.Len(id)), nil
+}
+
+func (s *SRTPTransport) unprotect(msg []byte) ([]byte, error) {
+	n := 0
+	if !s.sender {
+		var err error
+		if n, err = flowIDLen(msg); err != nil {
+			return nil, err
+		}
+	}
+	var pkt []byte
+	var err error
+	if isRTCP(msg[n:]) {
+		pkt, err = s.remote.DecryptRTCP(nil, msg[n:], nil)
+	} else {
+		pkt, err = s.remote.DecryptRTP(nil, msg[n:], nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(msg[:n:n], pkt...), nil
+}
+
+func (s *SRTPTransport) protect(msg []byte) ([]byte, error) {
+	n := 0
+	if s.sender {
+		var err error
+		if n, err = flowIDLen(msg); err != nil {
+			return nil, err
+		}
+	}
+	s.localLock.Lock()
+	defer s.localLock.Unlock()
+	var pkt []byte
+	var err error
+	if isRTCP(msg[n:]) {
+		pkt, err = s.local.EncryptRTCP(nil, msg[n:], nil)
+	} else {
+		pkt, err = s.local.EncryptRTP(nil, msg[n:], nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, 1+n+len(pkt))
+	buf = append(buf, srtpMessage)
+	buf = append(buf, msg[:n]...)
+	return append(buf, pkt...), nil
+}
+
+func (s *SRTPTransport) SendMessage(msg []byte, onSent func(error), onAcked func(bool)) error {
+	if err := s.keysReady(); err != nil {
+		return err
+	}
+	buf, err := s.protect(msg)
+	if err != nil {
+		return err
+	}
+	return s.Transport.SendMessage(buf, onSent, onAcked)
+}
+
+func (s *SRTPTransport) ReceiveMessage() ([]byte, error) {
+	msg, ok := <-s.in
+	if !ok {
+		return nil, s.readErr
+	}
+	return msg, nil
+}
+
+func (s *SRTPTransport) CloseWithError(code int, msg string) error {
+	if s.dtls != nil {
+		s.dtls.Close()
+	}
+	return s.Transport.CloseWithError(code, msg)
+}
+
+// HandleRTCP passes decrypted RTCP on to the wrapped transport, see
+// rtcpHandler.
+func (s *SRTPTransport) HandleRTCP(pkts []rtcp.Packet) {
+	if h, ok := s.Transport.(rtcpHandler); ok {
+		h.HandleRTCP(pkts)
+	}
+}
+
+// dtlsConn carries the DTLS records of the handshake over a Transport.
+type dtlsConn struct {
+	transport    Transport
+	in           chan []byte
+	readDeadline *deadline.Deadline
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newDTLSConn(t Transport) *dtlsConn {
+	return &dtlsConn{
+		transport:    t,
+		in:           make(chan []byte, 100),
+		readDeadline: deadline.New(),
+		done:         make(chan struct{}),
+	}
+}
+
+func (c *dtlsConn) push(record []byte) {
+	select {
+	case c.in <- record:
+	case <-c.done:
+	default:
+		log.Println("DTLS buffer full, dropping record")
+	}
+}
+
+func (c *dtlsConn) Read(b []byte) (int, error) {
+	select {
+	case record := <-c.in:
+		return copy(b, record), nil
+	case <-c.readDeadline.Done():
+		return 0, os.ErrDeadlineExceeded
+	case <-c.done:
+		return 0, net.ErrClosed
+	}
+}
+
+func (c *dtlsConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	if err := c.transport.SendMessage(append([]byte{dtlsMessage}, b...), nil, nil); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *dtlsConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	return nil
+}
+
+func (c *dtlsConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *dtlsConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	return nil
+}
+
+func (c *dtlsConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (c *dtlsConn) LocalAddr() net.Addr {
+	return dtlsAddr{}
+}
+
+func (c *dtlsConn) RemoteAddr() net.Addr {
+	return dtlsAddr{}
+}
+
+type dtlsAddr struct{}
+
+func (dtlsAddr) Network() string { return "rtc" }
+func (dtlsAddr) String() string  { return "transport" }
@@ -0,0 +1,184 @@
+package rtc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+// pipeTransport is one end of an in-memory Transport pair which records the
+// messages it sends.
+type pipeTransport struct {
+	in, out chan []byte
+
+	lock sync.Mutex
+	sent [][]byte
+
+	closeOnce sync.Once
+	done      chan struct{}
+
+	nonce []byte
+}
+
+func newPipeTransports() (*pipeTransport, *pipeTransport) {
+	a, b := make(chan []byte, 100), make(chan []byte, 100)
+	done := make(chan struct{})
+	nonce := make([]byte, 2*udpNonceLen)
+	_, _ = rand.Read(nonce)
+	return &pipeTransport{in: a, out: b, done: done, nonce: nonce}, &pipeTransport{in: b, out: a, done: done, nonce: nonce}
+}
+
+func (t *pipeTransport) SessionNonce() []byte {
+	return t.nonce
+}
+
+func (t *pipeTransport) SendMessage(msg []byte, _ func(error), _ func(bool)) error {
+	t.lock.Lock()
+	t.sent = append(t.sent, msg)
+	t.lock.Unlock()
+	select {
+	case t.out <- msg:
+		return nil
+	case <-t.done:
+		return errSessionClosed
+	}
+}
+
+func (t *pipeTransport) ReceiveMessage() ([]byte, error) {
+	select {
+	case msg := <-t.in:
+		return msg, nil
+	case <-t.done:
+		return nil, errSessionClosed
+	}
+}
+
+func (t *pipeTransport) CloseWithError(int, string) error {
+	t.closeOnce.Do(func() {
+		close(t.done)
+	})
+	return nil
+}
+
+func (t *pipeTransport) Metrics() RTTStats {
+	return RTTStats{}
+}
+
+func (t *pipeTransport) lastSent() []byte {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.sent[len(t.sent)-1]
+}
+
+func testSRTPTransport(t *testing.T, senderConf, receiverConf SRTPConfig) {
+	sendPipe, receivePipe := newPipeTransports()
+	sender, err := NewSRTPTransport(sendPipe, true, senderConf)
+	assert.NoError(t, err)
+	receiver, err := NewSRTPTransport(receivePipe, false, receiverConf)
+	assert.NoError(t, err)
+	defer sender.CloseWithError(0, "eos")
+
+	pkt := rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    96,
+			SequenceNumber: 1,
+			SSRC:           1,
+		},
+		Payload: []byte("payload"),
+	}
+	buf, err := pkt.Marshal()
+	assert.NoError(t, err)
+	msg := append(varintBytes(64), buf...)
+	assert.NoError(t, sender.SendMessage(msg, nil, nil))
+	assert.False(t, bytes.Contains(sendPipe.lastSent(), pkt.Payload))
+	received, err := receiver.ReceiveMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, msg, received)
+
+	report, err := rtcp.Marshal([]rtcp.Packet{&rtcp.ReceiverReport{SSRC: 2}})
+	assert.NoError(t, err)
+	assert.NoError(t, receiver.SendMessage(report, nil, nil))
+	assert.NotEqual(t, report, receivePipe.lastSent()[1:])
+	received, err = sender.ReceiveMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, report, received)
+}
+
+func TestSRTPTransportPreSharedKey(t *testing.T) {
+	key, err := ParseSRTPKey("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d")
+	assert.NoError(t, err)
+	testSRTPTransport(t, SRTPConfig{Key: key}, SRTPConfig{Key: key})
+
+	// Both directions use their own keys.
+	nonce := []byte("session")
+	senderKeys, receiverKeys := deriveSRTPKeys(key, nonce, true), deriveSRTPKeys(key, nonce, false)
+	assert.NotEqual(t, senderKeys.LocalMasterKey, senderKeys.RemoteMasterKey)
+	assert.NotEqual(t, senderKeys.LocalMasterSalt, senderKeys.RemoteMasterSalt)
+	assert.Equal(t, senderKeys.LocalMasterKey, receiverKeys.RemoteMasterKey)
+	assert.Equal(t, senderKeys.LocalMasterSalt, receiverKeys.RemoteMasterSalt)
+
+	// So do sessions, for example after reconnecting.
+	nextKeys := deriveSRTPKeys(key, []byte("next session"), true)
+	assert.NotEqual(t, senderKeys.LocalMasterKey, nextKeys.LocalMasterKey)
+	assert.NotEqual(t, senderKeys.LocalMasterSalt, nextKeys.LocalMasterSalt)
+
+	pipe, _ := newPipeTransports()
+	_, err = NewSRTPTransport(struct{ Transport }{pipe}, true, SRTPConfig{Key: key})
+	assert.Equal(t, errNoSessionNonce, err)
+}
+
+func TestSRTPTransportDTLS(t *testing.T) {
+	serverConf, err := NewServerTLSConfig(TLSConfig{})
+	assert.NoError(t, err)
+	clientConf, err := NewClientTLSConfig(TLSConfig{Pins: []string{Fingerprint(serverConf.Certificates[0])}})
+	assert.NoError(t, err)
+	testSRTPTransport(t, SRTPConfig{DTLS: clientConf}, SRTPConfig{DTLS: serverConf})
+}
+
+func TestSRTPTransportDTLSPinMismatch(t *testing.T) {
+	serverConf, err := NewServerTLSConfig(TLSConfig{})
+	assert.NoError(t, err)
+	clientConf, err := NewClientTLSConfig(TLSConfig{Pins: []string{strings.Repeat("00", 32)}})
+	assert.NoError(t, err)
+	sendPipe, receivePipe := newPipeTransports()
+	sender, err := NewSRTPTransport(sendPipe, true, SRTPConfig{DTLS: clientConf})
+	assert.NoError(t, err)
+	_, err = NewSRTPTransport(receivePipe, false, SRTPConfig{DTLS: serverConf})
+	assert.NoError(t, err)
+	defer sender.CloseWithError(0, "eos")
+	err = sender.SendMessage(varintBytes(64), nil, nil)
+	assert.True(t, errors.Is(err, errPinMismatch))
+
+	// Senders must verify the receiver.
+	clientConf, err = NewClientTLSConfig(TLSConfig{})
+	assert.NoError(t, err)
+	_, err = NewSRTPTransport(sendPipe, true, SRTPConfig{DTLS: clientConf})
+	assert.Equal(t, errUnverifiedDTLS, err)
+}
+
+func TestSRTPTransportClose(t *testing.T) {
+	sendPipe, receivePipe := newPipeTransports()
+	serverConf, err := NewServerTLSConfig(TLSConfig{})
+	assert.NoError(t, err)
+	receiver, err := NewSRTPTransport(receivePipe, false, SRTPConfig{DTLS: serverConf})
+	assert.NoError(t, err)
+	assert.NoError(t, sendPipe.CloseWithError(0, "eos"))
+	_, err = receiver.ReceiveMessage()
+	assert.True(t, errors.Is(err, errSessionClosed))
+	assert.Error(t, receiver.SendMessage([]byte{0}, nil, nil))
+}
+
+func TestParseSRTPKey(t *testing.T) {
+	_, err := ParseSRTPKey("00")
+	assert.Error(t, err)
+	_, err = ParseSRTPKey("zz")
+	assert.Error(t, err)
+}
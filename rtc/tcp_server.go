@@ -2,11 +2,17 @@ package rtc
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
 )
 
 type TCPServer struct {
@@ -33,20 +39,31 @@ func (s *TCPServer) Listen(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		receiver, err := s.makeReceiver(&tcpTransport{
-			conn: conn,
-		}, s.sinkFactory)
-		if err != nil {
-			log.Printf("failed to create receiver: %v\n", err)
-			continue
-		}
-		go func() {
-			log.Println("starting receiver")
-			defer receiver.Close()
-			if err := receiver.run(ctx); err != nil {
-				log.Printf("receiver closed connection: %v\n", err)
-			}
-		}()
+		go s.handleConn(ctx, conn)
+	}
+}
+
+func (s *TCPServer) handleConn(ctx context.Context, conn net.Conn) {
+	nonce, err := ExchangeTCPNonces(conn, false)
+	if err != nil {
+		log.Printf("%v, closing connection\n", err)
+		conn.Close()
+		return
+	}
+	receiver, err := s.makeReceiver(&tcpTransport{
+		TCPMetricer: NewTCPMetricer(conn),
+		conn:        conn,
+		nonce:       nonce,
+	}, s.sinkFactory)
+	if err != nil {
+		log.Printf("failed to create receiver: %v\n", err)
+		conn.Close()
+		return
+	}
+	log.Println("starting receiver")
+	defer receiver.Close()
+	if err := receiver.run(ctx); err != nil {
+		log.Printf("receiver closed connection: %v\n", err)
 	}
 }
 
@@ -54,8 +71,64 @@ func (s *TCPServer) Close() error {
 	return s.listener.Close()
 }
 
+// Both sides of a TCP connection start by sending a frame with a random
+// nonce, see ExchangeTCPNonces.
+const (
+	tcpNonceLen         = 16
+	tcpHandshakeTimeout = 5 * time.Second
+)
+
+// ExchangeTCPNonces sends a random nonce on conn and reads the peer's. It
+// returns both nonces, the client's first, which differ for every connection
+// like the nonces of UDP sessions, see UDPClient.SessionNonce. client must be
+// true on the side which dialed conn.
+func ExchangeTCPNonces(conn net.Conn, client bool) ([]byte, error) {
+	local := make([]byte, tcpNonceLen)
+	if _, err := rand.Read(local); err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(tcpHandshakeTimeout)); err != nil {
+		return nil, err
+	}
+	// Like a UDP hello, the client sends its nonce first and the server
+	// answers with its own.
+	t := &tcpTransport{conn: conn}
+	var remote []byte
+	var err error
+	if client {
+		if err = t.SendMessage(local, nil, nil); err != nil {
+			return nil, fmt.Errorf("failed to send TCP nonce: %w", err)
+		}
+	}
+	if remote, err = t.ReceiveMessage(); err != nil {
+		return nil, fmt.Errorf("failed to receive TCP nonce: %w", err)
+	}
+	if len(remote) != tcpNonceLen {
+		return nil, fmt.Errorf("invalid TCP nonce length: %v", len(remote))
+	}
+	if !client {
+		if err = t.SendMessage(local, nil, nil); err != nil {
+			return nil, fmt.Errorf("failed to send TCP nonce: %w", err)
+		}
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	if client {
+		return append(local, remote...), nil
+	}
+	return append(remote, local...), nil
+}
+
 type tcpTransport struct {
-	conn net.Conn
+	*TCPMetricer
+	conn  net.Conn
+	nonce []byte
+}
+
+// SessionNonce returns the nonces exchanged by ExchangeTCPNonces.
+func (t *tcpTransport) SessionNonce() []byte {
+	return t.nonce
 }
 
 func (t *tcpTransport) ReceiveMessage() ([]byte, error) {
@@ -89,6 +162,93 @@ func (t *tcpTransport) CloseWithError(_ int, _ string) error {
 	return t.conn.Close()
 }
 
-func (t *tcpTransport) Metrics() RTTStats {
-	panic(fmt.Errorf("UDP does not provide metrics"))
+// tcpLossRateSegments is the minimum number of segments over which
+// TCPMetricer computes the loss rate.
+const tcpLossRateSegments = 100
+
+// tcpInfo extends unix.TCPInfo by the fields up to tcpi_data_segs_out, which
+// are only available since Linux 4.6 and zero on older kernels.
+type tcpInfo struct {
+	unix.TCPInfo
+	PacingRate    uint64
+	MaxPacingRate uint64
+	BytesAcked    uint64
+	BytesReceived uint64
+	SegsOut       uint32
+	SegsIn        uint32
+	NotsentBytes  uint32
+	MinRTT        uint32
+	DataSegsIn    uint32
+	DataSegsOut   uint32
+}
+
+func getTCPInfo(conn net.Conn) (*tcpInfo, error) {
+	rc, err := rawConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	var info tcpInfo
+	var serr error
+	if err = rc.Control(func(fd uintptr) {
+		size := uint32(unsafe.Sizeof(info))
+		_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, fd, unix.SOL_TCP, unix.TCP_INFO, uintptr(unsafe.Pointer(&info)), uintptr(unsafe.Pointer(&size)), 0)
+		if errno != 0 {
+			serr = errno
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if serr != nil {
+		return nil, serr
+	}
+	return &info, nil
+}
+
+// stats returns RTT, congestion window and retransmissions from TCP_INFO.
+func (info *tcpInfo) stats() RTTStats {
+	srtt := time.Duration(info.Rtt) * time.Microsecond
+	return RTTStats{
+		MinRTT:      time.Duration(info.MinRTT) * time.Microsecond,
+		SmoothedRTT: srtt,
+		RTTVar:      time.Duration(info.Rttvar) * time.Microsecond,
+		// TCP_INFO only exposes the smoothed RTT
+		LatestRTT: srtt,
+		Cwnd:      uint64(info.Snd_cwnd) * uint64(info.Snd_mss),
+		Lost:      uint64(info.Total_retrans),
+	}
+}
+
+// TCPMetricer reads the metrics of a TCP connection from TCP_INFO. The loss
+// rate is the fraction of retransmitted segments among the most recent
+// tcpLossRateSegments or more data segments.
+type TCPMetricer struct {
+	conn net.Conn
+
+	lock        sync.Mutex
+	retrans     uint32
+	dataSegsOut uint32
+	lossRate    float64
+}
+
+func NewTCPMetricer(conn net.Conn) *TCPMetricer {
+	return &TCPMetricer{
+		conn: conn,
+	}
+}
+
+func (m *TCPMetricer) Metrics() RTTStats {
+	info, err := getTCPInfo(m.conn)
+	if err != nil {
+		log.Printf("failed to get TCP metrics: %v\n", err)
+		return RTTStats{}
+	}
+	stats := info.stats()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if segs := info.DataSegsOut - m.dataSegsOut; segs >= tcpLossRateSegments {
+		m.lossRate = float64(info.Total_retrans-m.retrans) / float64(segs)
+		m.retrans, m.dataSegsOut = info.Total_retrans, info.DataSegsOut
+	}
+	stats.LossRate = m.lossRate
+	return stats
 }
@@ -0,0 +1,60 @@
+package rtc
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTCPMetricer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	for i := 0; i < 2*tcpLossRateSegments; i++ {
+		_, err = conn.Write(make([]byte, 100))
+		assert.NoError(t, err)
+	}
+
+	stats := NewTCPMetricer(conn).Metrics()
+	assert.NotZero(t, stats.SmoothedRTT)
+	assert.NotZero(t, stats.MinRTT)
+	assert.LessOrEqual(t, int64(stats.MinRTT), int64(stats.SmoothedRTT))
+	assert.NotZero(t, stats.Cwnd)
+	assert.Zero(t, stats.LossRate)
+}
+
+func TestExchangeTCPNonces(t *testing.T) {
+	exchange := func() ([]byte, []byte) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+		serverNonce := make(chan []byte, 1)
+		go func() {
+			nonce, err := ExchangeTCPNonces(server, false)
+			assert.NoError(t, err)
+			serverNonce <- nonce
+		}()
+		nonce, err := ExchangeTCPNonces(client, true)
+		assert.NoError(t, err)
+		return nonce, <-serverNonce
+	}
+	client, server := exchange()
+	assert.Len(t, client, 2*tcpNonceLen)
+	assert.Equal(t, client, server)
+
+	next, _ := exchange()
+	assert.NotEqual(t, client, next)
+}
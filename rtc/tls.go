@@ -1,17 +1,194 @@
 package rtc
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
 	"math/big"
+	"strings"
 )
 
+// TLSConfig selects the certificates used to authenticate the QUIC
+// connection. The same settings are used by both sides, each one verifying the
+// other.
+type TLSConfig struct {
+	// CertFile and KeyFile contain the PEM encoded certificate chain and
+	// private key presented to the peer. If empty, the server generates a
+	// self-signed certificate and the client doesn't present any.
+	CertFile string
+	KeyFile  string
+
+	// CAFile contains PEM encoded CA certificates which must have issued the
+	// peer's certificate.
+	CAFile string
+
+	// Pins are hex encoded SHA-256 fingerprints of which the peer's
+	// certificate must match one, see Fingerprint.
+	Pins []string
+
+	// ServerName is the name the client expects in the server's certificate
+	// if it is verified by CAFile, usually the host of the server address.
+	ServerName string
+}
+
+func (c TLSConfig) verifiesPeer() bool {
+	return len(c.CAFile) > 0 || len(c.Pins) > 0
+}
+
+// NewServerTLSConfig creates the TLS config of the receiver. Clients have to
+// present a certificate if a CA or pins are configured.
+func NewServerTLSConfig(c TLSConfig) (*tls.Config, error) {
+	var conf *tls.Config
+	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		conf = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"rtq"},
+		}
+	} else {
+		conf = generateTLSConfig()
+	}
+	log.Printf("TLS certificate fingerprint: %v\n", Fingerprint(conf.Certificates[0]))
+
+	if !c.verifiesPeer() {
+		return conf, nil
+	}
+	conf.ClientAuth = tls.RequireAnyClientCert
+	if len(c.CAFile) > 0 {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.ClientCAs = pool
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if err := setPins(conf, c.Pins); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// NewClientTLSConfig creates the TLS config of the sender. Without a CA or
+// pins, the server's certificate is not verified. Session tickets are cached
+// in the config, so it should be reused when reconnecting to resume sessions.
+func NewClientTLSConfig(c TLSConfig) (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         c.ServerName,
+		NextProtos:         []string{"rtq"},
+		ClientSessionCache: tls.NewLRUClientSessionCache(0),
+	}
+	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+		log.Printf("TLS certificate fingerprint: %v\n", Fingerprint(cert))
+	}
+
+	if len(c.CAFile) > 0 {
+		// Any certificate issued by the CA would be accepted otherwise.
+		if len(c.ServerName) == 0 {
+			return nil, errNoServerName
+		}
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = pool
+	} else {
+		// Verification only by pins, if any.
+		conf.InsecureSkipVerify = true
+	}
+	if err := setPins(conf, c.Pins); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pemCerts, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return nil, fmt.Errorf("no certificates found in CA file %v", file)
+	}
+	return pool, nil
+}
+
+var errNoServerName = errors.New("verifying the server's certificate by a CA requires a server name")
+
+var errPinMismatch = errors.New("peer certificate does not match any pinned fingerprint")
+
+// setPins makes conf reject peers whose leaf certificate doesn't match one of
+// pins.
+func setPins(conf *tls.Config, pins []string) error {
+	if len(pins) == 0 {
+		return nil
+	}
+	fingerprints := make([][]byte, 0, len(pins))
+	for _, pin := range pins {
+		fp, err := parseFingerprint(pin)
+		if err != nil {
+			return err
+		}
+		fingerprints = append(fingerprints, fp)
+	}
+	conf.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errPinMismatch
+		}
+		sum := sha256.Sum256(rawCerts[0])
+		for _, fp := range fingerprints {
+			if bytes.Equal(sum[:], fp) {
+				return nil
+			}
+		}
+		return errPinMismatch
+	}
+	return nil
+}
+
+// parseFingerprint parses a hex encoded SHA-256 fingerprint, optionally
+// separated by colons.
+func parseFingerprint(s string) ([]byte, error) {
+	fp, err := hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid fingerprint %v: %w", s, err)
+	}
+	if len(fp) != sha256.Size {
+		return nil, fmt.Errorf("invalid fingerprint %v: expected %v bytes, got %v", s, sha256.Size, len(fp))
+	}
+	return fp, nil
+}
+
+// Fingerprint returns the hex encoded SHA-256 fingerprint of the leaf
+// certificate of cert as used by TLSConfig.Pins.
+func Fingerprint(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	return hex.EncodeToString(sum[:])
+}
+
 // Setup a bare-bones TLS config for the server
 func generateTLSConfig() *tls.Config {
-	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,160 @@
+package rtc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA
+// certificate if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		DNSNames:              []string{name},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	c := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, "cert.pem"),
+		keyFile:  filepath.Join(dir, "key.pem"),
+	}
+	assert.NoError(t, os.WriteFile(c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return c
+}
+
+func (c *testCert) fingerprint(t *testing.T) string {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	assert.NoError(t, err)
+	return Fingerprint(cert)
+}
+
+// tlsHandshake connects a client to a server and returns the first error
+// either side encountered. With TLS 1.3, the client only learns that the
+// server rejected its certificate when reading from the connection.
+func tlsHandshake(t *testing.T, server, client TLSConfig) error {
+	serverConf, err := NewServerTLSConfig(server)
+	assert.NoError(t, err)
+	client.ServerName = "receiver"
+	clientConf, err := NewClientTLSConfig(client)
+	assert.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConf)
+	assert.NoError(t, err)
+	defer listener.Close()
+	errCh := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer conn.Close()
+		_, err = conn.Write([]byte{1})
+		errCh <- err
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), clientConf)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		return err
+	}
+	return <-errCh
+}
+
+func TestTLSServerPinning(t *testing.T) {
+	receiver := newTestCert(t, "receiver", nil)
+	server := TLSConfig{CertFile: receiver.certFile, KeyFile: receiver.keyFile}
+
+	assert.NoError(t, tlsHandshake(t, server, TLSConfig{Pins: []string{receiver.fingerprint(t)}}))
+
+	other := newTestCert(t, "receiver", nil)
+	assert.Error(t, tlsHandshake(t, server, TLSConfig{Pins: []string{other.fingerprint(t)}}))
+}
+
+func TestTLSServerCA(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	receiver := newTestCert(t, "receiver", ca)
+	server := TLSConfig{CertFile: receiver.certFile, KeyFile: receiver.keyFile}
+
+	assert.NoError(t, tlsHandshake(t, server, TLSConfig{CAFile: ca.certFile}))
+
+	otherCA := newTestCert(t, "ca", nil)
+	assert.Error(t, tlsHandshake(t, server, TLSConfig{CAFile: otherCA.certFile}))
+
+	// Certificates of the same CA for other names are rejected.
+	impostor := newTestCert(t, "impostor", ca)
+	assert.Error(t, tlsHandshake(t, TLSConfig{CertFile: impostor.certFile, KeyFile: impostor.keyFile}, TLSConfig{CAFile: ca.certFile}))
+
+	_, err := NewClientTLSConfig(TLSConfig{CAFile: ca.certFile})
+	assert.Equal(t, errNoServerName, err)
+}
+
+func TestTLSClientAuthentication(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	aircraft := newTestCert(t, "aircraft", ca)
+	server := TLSConfig{CAFile: ca.certFile}
+
+	assert.NoError(t, tlsHandshake(t, server, TLSConfig{CertFile: aircraft.certFile, KeyFile: aircraft.keyFile}))
+	assert.Error(t, tlsHandshake(t, server, TLSConfig{}))
+
+	stranger := newTestCert(t, "stranger", nil)
+	assert.Error(t, tlsHandshake(t, server, TLSConfig{CertFile: stranger.certFile, KeyFile: stranger.keyFile}))
+
+	pinned := TLSConfig{Pins: []string{strings.ToUpper(aircraft.fingerprint(t))}}
+	assert.NoError(t, tlsHandshake(t, pinned, TLSConfig{CertFile: aircraft.certFile, KeyFile: aircraft.keyFile}))
+	assert.Error(t, tlsHandshake(t, pinned, TLSConfig{CertFile: stranger.certFile, KeyFile: stranger.keyFile}))
+}
+
+func TestParseFingerprint(t *testing.T) {
+	_, err := parseFingerprint("00:11")
+	assert.Error(t, err)
+	fp, err := parseFingerprint(strings.Repeat("ab:", 31) + "ab")
+	assert.NoError(t, err)
+	assert.Len(t, fp, 32)
+}
@@ -0,0 +1,289 @@
+package rtc
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Every UDP datagram starts with one of the following message types, followed
+// by the 8 byte session ID chosen by the client. Data messages carry RTP or
+// RTCP, the others control the lifetime of a session: The client opens a
+// session with a hello which the server answers, the client sends keepalives
+// which the server echoes, and either side ends the session with a bye.
+// Because sessions are identified by their ID instead of the client's
+// address, they survive NAT rebindings. The hellos of both sides carry a
+// random nonce, see SessionNonce.
+const (
+	udpData byte = iota
+	udpHello
+	udpKeepalive
+	udpBye
+)
+
+const (
+	udpHelloInterval     = 250 * time.Millisecond
+	udpHandshakeTimeout  = 5 * time.Second
+	udpKeepaliveInterval = 1 * time.Second
+	udpIdleTimeout       = 10 * time.Second
+)
+
+const (
+	udpHeaderLen = 9
+	udpNonceLen  = 16
+)
+
+var (
+	errSessionClosed      = errors.New("session closed")
+	errUDPMessageTooShort = errors.New("UDP message too short")
+)
+
+func udpMessage(typ byte, id uint64, payload []byte) []byte {
+	buf := make([]byte, udpHeaderLen+len(payload))
+	buf[0] = typ
+	binary.BigEndian.PutUint64(buf[1:udpHeaderLen], id)
+	copy(buf[udpHeaderLen:], payload)
+	return buf
+}
+
+func parseUDPMessage(buf []byte) (byte, uint64, []byte, error) {
+	if len(buf) < udpHeaderLen {
+		return 0, 0, nil, errUDPMessageTooShort
+	}
+	return buf[0], binary.BigEndian.Uint64(buf[1:udpHeaderLen]), buf[udpHeaderLen:], nil
+}
+
+// UDPClient is the sending side of a UDP session with a UDPServer.
+type UDPClient struct {
+	*RTCPTracker
+	id    uint64
+	nonce []byte
+
+	lock sync.Mutex
+	conn *net.UDPConn
+	ecn  ECN
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// DialUDP opens a session with the UDPServer at addr.
+func DialUDP(addr string) (*UDPClient, error) {
+	return DialUDPFrom(nil, addr)
+}
+
+// DialUDPFrom opens a session with the UDPServer at addr from the local
+// address local. If local is nil, the address is chosen by the system.
+func DialUDPFrom(local net.IP, addr string) (*UDPClient, error) {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	var laddr *net.UDPAddr
+	if local != nil {
+		laddr = &net.UDPAddr{IP: local}
+	}
+	conn, err := net.DialUDP("udp", laddr, a)
+	if err != nil {
+		return nil, err
+	}
+	var id [8]byte
+	nonce := make([]byte, udpNonceLen, 2*udpNonceLen)
+	if _, err := rand.Read(id[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := &UDPClient{
+		RTCPTracker: NewRTCPTracker(),
+		conn:        conn,
+		id:          binary.BigEndian.Uint64(id[:]),
+		nonce:       nonce,
+		done:        make(chan struct{}),
+	}
+	if err := c.handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go c.keepalive()
+	return c, nil
+}
+
+func (c *UDPClient) handshake() error {
+	deadline := time.Now().Add(udpHandshakeTimeout)
+	buf := make([]byte, 1500)
+	for time.Now().Before(deadline) {
+		if _, err := c.conn.Write(udpMessage(udpHello, c.id, c.nonce)); err != nil {
+			return err
+		}
+		if err := c.conn.SetReadDeadline(time.Now().Add(udpHelloInterval)); err != nil {
+			return err
+		}
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		typ, id, payload, err := parseUDPMessage(buf[:n])
+		if err == nil && typ == udpHello && id == c.id && len(payload) == udpNonceLen {
+			c.nonce = append(c.nonce, payload...)
+			log.Printf("UDP session %x with %v established\n", c.id, c.conn.RemoteAddr())
+			return nil
+		}
+	}
+	return fmt.Errorf("UDP handshake with %v timed out", c.conn.RemoteAddr())
+}
+
+func (c *UDPClient) keepalive() {
+	ticker := time.NewTicker(udpKeepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := c.getConn().Write(udpMessage(udpKeepalive, c.id, nil)); err != nil {
+				log.Printf("failed to send UDP keepalive: %v\n", err)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// SessionNonce returns the nonces of the client and the server exchanged in
+// the hellos. They differ for every session, even if it is opened by the same
+// sender again.
+func (c *UDPClient) SessionNonce() []byte {
+	return c.nonce
+}
+
+func (c *UDPClient) getConn() *net.UDPConn {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.conn
+}
+
+// SetECN marks all further packets of the session with ecn.
+func (c *UDPClient) SetECN(ecn ECN) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := setECN(c.conn, ecn); err != nil {
+		return fmt.Errorf("failed to set ECN to %v: %w", ecn, err)
+	}
+	c.ecn = ecn
+	return nil
+}
+
+// Rebind moves the session to a new socket bound to local. The server follows
+// the session to the new address like after a NAT rebinding.
+func (c *UDPClient) Rebind(local net.IP) error {
+	old := c.getConn()
+	conn, err := net.DialUDP("udp", &net.UDPAddr{IP: local}, old.RemoteAddr().(*net.UDPAddr))
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	if c.isClosed() {
+		c.lock.Unlock()
+		conn.Close()
+		return errSessionClosed
+	}
+	if c.ecn != NotECT {
+		if err := setECN(conn, c.ecn); err != nil {
+			log.Printf("failed to set ECN to %v: %v\n", c.ecn, err)
+		}
+	}
+	c.conn = conn
+	c.lock.Unlock()
+	if _, err := conn.Write(udpMessage(udpKeepalive, c.id, nil)); err != nil {
+		log.Printf("failed to send UDP keepalive: %v\n", err)
+	}
+	return old.Close()
+}
+
+func (c *UDPClient) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *UDPClient) SendMessage(msg []byte, _ func(error), _ func(bool)) error {
+	if c.isClosed() {
+		return errSessionClosed
+	}
+	_, err := c.getConn().Write(udpMessage(udpData, c.id, msg))
+	return err
+}
+
+// ReceiveMessage returns the next data message. It fails if the server ends
+// the session or if nothing, not even a keepalive, was received for
+// udpIdleTimeout.
+func (c *UDPClient) ReceiveMessage() ([]byte, error) {
+	buf := make([]byte, 1500)
+	for {
+		if c.isClosed() {
+			return nil, errSessionClosed
+		}
+		conn := c.getConn()
+		if err := conn.SetReadDeadline(time.Now().Add(udpIdleTimeout)); err != nil {
+			if conn != c.getConn() {
+				continue
+			}
+			return nil, err
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			if conn != c.getConn() {
+				// rebound
+				continue
+			}
+			return nil, err
+		}
+		typ, id, payload, err := parseUDPMessage(buf[:n])
+		if err != nil || id != c.id {
+			continue
+		}
+		switch typ {
+		case udpData:
+			return payload, nil
+		case udpBye:
+			log.Printf("UDP session closed by %v\n", conn.RemoteAddr())
+			c.close(false)
+			return nil, errSessionClosed
+		}
+	}
+}
+
+func (c *UDPClient) CloseWithError(int, string) error {
+	return c.close(true)
+}
+
+func (c *UDPClient) close(sendBye bool) error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.lock.Lock()
+		close(c.done)
+		conn := c.conn
+		c.lock.Unlock()
+		if sendBye {
+			if _, werr := conn.Write(udpMessage(udpBye, c.id, nil)); werr != nil {
+				log.Printf("failed to send UDP bye: %v\n", werr)
+			}
+		}
+		err = conn.Close()
+	})
+	return err
+}
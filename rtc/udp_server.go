@@ -2,17 +2,22 @@ package rtc
 
 import (
 	"context"
-	"fmt"
+	"crypto/rand"
 	"log"
 	"net"
+	"sync"
 	"time"
 )
 
 type UDPServer struct {
 	conn         *net.UDPConn
-	clients      map[string]*udpTransport
 	makeReceiver ReceiverFactory
 	sinkFactory  MediaSinkFactory
+
+	lock      sync.Mutex
+	clients   map[uint64]*udpTransport
+	wg        sync.WaitGroup
+	closeOnce sync.Once
 }
 
 func NewUDPServer(f ReceiverFactory, addr string, sinkFactory MediaSinkFactory) (*UDPServer, error) {
@@ -25,83 +30,283 @@ func NewUDPServer(f ReceiverFactory, addr string, sinkFactory MediaSinkFactory)
 	if err != nil {
 		return nil, err
 	}
+	if err := enableECNReceive(conn); err != nil {
+		log.Printf("failed to enable receiving ECN: %v\n", err)
+	}
 	return &UDPServer{
 		conn:         conn,
-		clients:      map[string]*udpTransport{},
+		clients:      map[uint64]*udpTransport{},
 		makeReceiver: f,
 		sinkFactory:  sinkFactory,
 	}, nil
 }
 
 func (s *UDPServer) Listen(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+	go s.evictIdle(ctx)
+
+	oob := make([]byte, 128)
 	for {
 		buf := make([]byte, 1500)
-		n, addr, err := s.conn.ReadFromUDP(buf)
+		n, oobn, _, addr, err := s.conn.ReadMsgUDP(buf, oob)
 		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+			}
 			// TODO: Check if this is correct?
 			return err
 		}
-		key := fmt.Sprintf("%v:%v", addr.IP.To16(), addr.Port)
-		client, ok := s.clients[key]
-		if !ok {
-			client = &udpTransport{
-				conn: s.conn,
-				addr: addr,
-				in:   make(chan []byte, 1000),
+		typ, id, payload, err := parseUDPMessage(buf[:n])
+		if err != nil {
+			log.Printf("got invalid UDP message from %v: %v, dropping message\n", addr, err)
+			continue
+		}
+		s.lock.Lock()
+		client, ok := s.clients[id]
+		s.lock.Unlock()
+		if ok {
+			client.touch(addr)
+		}
+
+		switch typ {
+		case udpHello:
+			if !ok {
+				if len(payload) != udpNonceLen {
+					log.Printf("got UDP hello without nonce from %v, dropping message\n", addr)
+					continue
+				}
+				if client = s.accept(ctx, id, addr, payload); client == nil {
+					continue
+				}
+			}
+			s.send(addr, udpMessage(udpHello, id, client.nonce[udpNonceLen:]))
+
+		case udpKeepalive:
+			if ok {
+				s.send(addr, udpMessage(udpKeepalive, id, nil))
 			}
-			receiver, err := s.makeReceiver(client, s.sinkFactory)
-			if err != nil {
-				log.Printf("failed to create receiver: %v\n", err)
+
+		case udpBye:
+			if ok {
+				log.Printf("UDP session %x closed by peer\n", id)
+				client.close(false)
+			}
+
+		case udpData:
+			if !ok {
+				// Tell the peer that its session doesn't exist (anymore).
+				s.send(addr, udpMessage(udpBye, id, nil))
 				continue
 			}
-			go func() {
-				log.Println("starting receiver")
-				defer receiver.Close()
-				err := receiver.run(ctx)
-				if err != nil {
-					log.Printf("receiver closed connection: %v\n", err)
-				}
-				close(client.in)
-			}()
-			s.clients[key] = client
+			select {
+			case client.in <- udpPacket{payload: payload, ecn: readECN(oob[:oobn])}:
+			default:
+				log.Println("client buffer full, dropping message")
+			}
+
+		default:
+			log.Printf("got UDP message of unknown type %v from %v, dropping message\n", typ, addr)
+		}
+	}
+}
+
+// accept opens the session with id of a client which sent clientNonce in its
+// hello. It returns nil if no Receiver could be created.
+func (s *UDPServer) accept(ctx context.Context, id uint64, addr *net.UDPAddr, clientNonce []byte) *udpTransport {
+	nonce := make([]byte, 2*udpNonceLen)
+	copy(nonce, clientNonce)
+	if _, err := rand.Read(nonce[udpNonceLen:]); err != nil {
+		log.Printf("failed to create UDP session nonce: %v\n", err)
+		return nil
+	}
+	client := &udpTransport{
+		RTCPTracker: NewRTCPTracker(),
+		conn:        s.conn,
+		id:          id,
+		nonce:       nonce,
+		addr:        addr,
+		in:          make(chan udpPacket, 1000),
+		lastSeen:    time.Now(),
+		done:        make(chan struct{}),
+	}
+	client.onClose = func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if s.clients[id] == client {
+			delete(s.clients, id)
+		}
+	}
+	receiver, err := s.makeReceiver(client, s.sinkFactory)
+	if err != nil {
+		log.Printf("failed to create receiver: %v\n", err)
+		return nil
+	}
+	log.Printf("new UDP session %x from %v\n", id, addr)
+	s.lock.Lock()
+	s.clients[id] = client
+	s.lock.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		log.Println("starting receiver")
+		defer receiver.Close()
+		err := receiver.run(ctx)
+		if err != nil {
+			log.Printf("receiver closed connection: %v\n", err)
 		}
+	}()
+	return client
+}
+
+func (s *UDPServer) send(addr *net.UDPAddr, msg []byte) {
+	if _, err := s.conn.WriteTo(msg, addr); err != nil {
+		log.Printf("failed to send UDP message to %v: %v\n", addr, err)
+	}
+}
+
+// evictIdle closes sessions which didn't receive anything for
+// udpIdleTimeout.
+func (s *UDPServer) evictIdle(ctx context.Context) {
+	ticker := time.NewTicker(udpKeepaliveInterval)
+	defer ticker.Stop()
+	for {
 		select {
-		case client.in <- buf[:n]:
-		default:
-			log.Println("client buffer full, dropping message")
+		case <-ticker.C:
+			s.lock.Lock()
+			var idle []*udpTransport
+			for id, client := range s.clients {
+				if client.idle() > udpIdleTimeout {
+					log.Printf("UDP session %x idle for %v, closing\n", id, client.idle())
+					idle = append(idle, client)
+				}
+			}
+			s.lock.Unlock()
+			for _, client := range idle {
+				client.close(true)
+			}
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
+// Close ends all sessions, waits for their receivers to shut down and closes
+// the socket.
 func (s *UDPServer) Close() error {
-	return s.conn.Close()
+	var err error
+	s.closeOnce.Do(func() {
+		s.lock.Lock()
+		clients := make([]*udpTransport, 0, len(s.clients))
+		for _, client := range s.clients {
+			clients = append(clients, client)
+		}
+		s.lock.Unlock()
+		for _, client := range clients {
+			client.close(true)
+		}
+		s.wg.Wait()
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// udpPacket is a received data message and the ECN codepoint of the packet
+// it arrived in.
+type udpPacket struct {
+	payload []byte
+	ecn     ECN
 }
 
 type udpTransport struct {
-	conn *net.UDPConn
-	addr *net.UDPAddr
-	in   chan []byte
+	*RTCPTracker
+	conn  *net.UDPConn
+	id    uint64
+	nonce []byte
+	in    chan udpPacket
+
+	lock     sync.Mutex
+	addr     *net.UDPAddr
+	lastSeen time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+	onClose   func()
+}
+
+// touch records activity of the session from addr. If addr differs from the
+// session's current address, the peer's NAT rebound it to a new address and
+// all further messages are sent there.
+func (t *udpTransport) touch(addr *net.UDPAddr) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.lastSeen = time.Now()
+	if !addr.IP.Equal(t.addr.IP) || addr.Port != t.addr.Port {
+		log.Printf("%v UDP session %x rebound from %v to %v\n", t.lastSeen.Format(time.RFC3339Nano), t.id, t.addr, addr)
+		t.addr = addr
+	}
+}
+
+// SessionNonce returns the nonces of the client and the server, see
+// UDPClient.SessionNonce.
+func (t *udpTransport) SessionNonce() []byte {
+	return t.nonce
+}
+
+func (t *udpTransport) remoteAddr() *net.UDPAddr {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.addr
+}
+
+func (t *udpTransport) idle() time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return time.Since(t.lastSeen)
 }
 
 func (t *udpTransport) ReceiveMessage() ([]byte, error) {
+	msg, _, err := t.ReceiveMessageECN()
+	return msg, err
+}
+
+func (t *udpTransport) ReceiveMessageECN() ([]byte, ECN, error) {
 	select {
-	case msg := <-t.in:
-		return msg, nil
-	case <-time.After(60 * time.Second):
-		return nil, fmt.Errorf("timeout")
+	case pkt := <-t.in:
+		return pkt.payload, pkt.ecn, nil
+	case <-t.done:
+		return nil, NotECT, errSessionClosed
 	}
 }
 
 func (t *udpTransport) SendMessage(msg []byte, _ func(error), _ func(bool)) error {
-	_, err := t.conn.WriteTo(msg, t.addr)
+	select {
+	case <-t.done:
+		return errSessionClosed
+	default:
+	}
+	_, err := t.conn.WriteTo(udpMessage(udpData, t.id, msg), t.remoteAddr())
 	return err
 }
 
 func (t *udpTransport) CloseWithError(code int, msg string) error {
-	// TODO
+	t.close(true)
 	return nil
 }
 
-func (t *udpTransport) Metrics() RTTStats {
-	panic(fmt.Errorf("UDP does not provide metrics"))
+func (t *udpTransport) close(sendBye bool) {
+	t.closeOnce.Do(func() {
+		if sendBye {
+			if _, err := t.conn.WriteTo(udpMessage(udpBye, t.id, nil), t.remoteAddr()); err != nil {
+				log.Printf("failed to send UDP bye: %v\n", err)
+			}
+		}
+		close(t.done)
+		t.onClose()
+	})
 }
@@ -0,0 +1,148 @@
+package rtc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestUDPServer(t *testing.T) (*UDPServer, context.CancelFunc) {
+	rf := func(session Transport, _ MediaSinkFactory) (*Receiver, error) {
+		return newReceiver(session, &interceptor.NoOp{})
+	}
+	server, err := NewUDPServer(rf, "127.0.0.1:0", nil)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	go server.Listen(ctx)
+	return server, cancel
+}
+
+func (s *UDPServer) numSessions() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.clients)
+}
+
+func TestUDPSessionBye(t *testing.T) {
+	server, cancel := newTestUDPServer(t)
+	defer cancel()
+
+	client, err := DialUDP(server.conn.LocalAddr().String())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, server.numSessions())
+
+	assert.NoError(t, client.CloseWithError(0, "eos"))
+	assert.Eventually(t, func() bool {
+		return server.numSessions() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestUDPSessionNonce(t *testing.T) {
+	server, cancel := newTestUDPServer(t)
+	defer cancel()
+
+	client, err := DialUDP(server.conn.LocalAddr().String())
+	assert.NoError(t, err)
+	defer client.CloseWithError(0, "eos")
+	assert.Len(t, client.SessionNonce(), 2*udpNonceLen)
+	server.lock.Lock()
+	assert.Equal(t, client.SessionNonce(), server.clients[client.id].SessionNonce())
+	server.lock.Unlock()
+
+	next, err := DialUDP(server.conn.LocalAddr().String())
+	assert.NoError(t, err)
+	defer next.CloseWithError(0, "eos")
+	assert.NotEqual(t, client.SessionNonce(), next.SessionNonce())
+}
+
+func TestUDPServerClose(t *testing.T) {
+	server, cancel := newTestUDPServer(t)
+	defer cancel()
+
+	client, err := DialUDP(server.conn.LocalAddr().String())
+	assert.NoError(t, err)
+
+	assert.NoError(t, server.Close())
+	assert.Equal(t, 0, server.numSessions())
+
+	_, err = client.ReceiveMessage()
+	assert.ErrorIs(t, err, errSessionClosed)
+	assert.ErrorIs(t, client.SendMessage([]byte{0}, nil, nil), errSessionClosed)
+}
+
+func TestUDPSessionRebinding(t *testing.T) {
+	server, cancel := newTestUDPServer(t)
+	defer cancel()
+
+	client, err := DialUDP(server.conn.LocalAddr().String())
+	assert.NoError(t, err)
+	defer client.CloseWithError(0, "eos")
+
+	// Simulate a NAT rebinding by continuing the session from a new socket.
+	rebound, err := net.DialUDP("udp", nil, server.conn.LocalAddr().(*net.UDPAddr))
+	assert.NoError(t, err)
+	defer rebound.Close()
+	_, err = rebound.Write(udpMessage(udpKeepalive, client.id, nil))
+	assert.NoError(t, err)
+
+	buf := make([]byte, 1500)
+	assert.NoError(t, rebound.SetReadDeadline(time.Now().Add(time.Second)))
+	n, err := rebound.Read(buf)
+	assert.NoError(t, err)
+	typ, id, _, err := parseUDPMessage(buf[:n])
+	assert.NoError(t, err)
+	assert.Equal(t, udpKeepalive, typ)
+	assert.Equal(t, client.id, id)
+	assert.Equal(t, 1, server.numSessions())
+
+	server.lock.Lock()
+	session := server.clients[client.id]
+	server.lock.Unlock()
+	assert.Equal(t, rebound.LocalAddr().String(), session.remoteAddr().String())
+}
+
+func TestUDPClientRebind(t *testing.T) {
+	server, cancel := newTestUDPServer(t)
+	defer cancel()
+
+	client, err := DialUDPFrom(net.IPv4(127, 0, 0, 1), server.conn.LocalAddr().String())
+	assert.NoError(t, err)
+	defer client.CloseWithError(0, "eos")
+
+	assert.NoError(t, client.Rebind(net.IPv4(127, 0, 0, 2)))
+	assert.NoError(t, client.SendMessage([]byte{0}, nil, nil))
+
+	server.lock.Lock()
+	session := server.clients[client.id]
+	server.lock.Unlock()
+	assert.Eventually(t, func() bool {
+		return session.remoteAddr().IP.Equal(net.IPv4(127, 0, 0, 2))
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, server.numSessions())
+}
+
+func TestECN(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	defer server.Close()
+	assert.NoError(t, enableECNReceive(server))
+
+	client, err := net.DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	assert.NoError(t, err)
+	defer client.Close()
+
+	buf := make([]byte, 1500)
+	oob := make([]byte, 128)
+	for _, ecn := range []ECN{ECT1, ECT0, NotECT} {
+		assert.NoError(t, setECN(client, ecn))
+		_, err = client.Write([]byte{0})
+		assert.NoError(t, err)
+		_, oobn, _, _, err := server.ReadMsgUDP(buf, oob)
+		assert.NoError(t, err)
+		assert.Equal(t, ecn, readECN(oob[:oobn]))
+	}
+}
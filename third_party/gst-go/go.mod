@@ -0,0 +1,3 @@
+module github.com/mengelbart/gst-go
+
+go 1.17
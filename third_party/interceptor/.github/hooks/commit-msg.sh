@@ -0,0 +1,11 @@
+#!/usr/bin/env bash
+
+#
+# DO NOT EDIT THIS FILE DIRECTLY
+#
+# It is automatically copied from https://github.com/pion/.goassets repository.
+#
+
+set -e
+
+.github/lint-commit-message.sh $1
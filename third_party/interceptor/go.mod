@@ -0,0 +1,11 @@
+module github.com/pion/interceptor
+
+go 1.15
+
+require (
+	github.com/mengelbart/scream-go v0.3.0
+	github.com/pion/logging v0.2.2
+	github.com/pion/rtcp v1.2.9
+	github.com/pion/rtp v1.7.4
+	github.com/stretchr/testify v1.7.0
+)
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+const addr = "localhost:4242"
+
+const message = "foobar"
+
+// We start a server echoing data on the first stream the client opens,
+// then connect with a client, send the message, and wait for its receipt.
+func main() {
+	go func() {
+		err := echoServer()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	err := clientMain()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Start a server that echos all data on the first stream opened by the client
+func echoServer() error {
+	listener, err := quic.ListenAddr(addr, generateTLSConfig(), &quic.Config{EnableDatagrams: true})
+	if err != nil {
+		return err
+	}
+	sess, err := listener.Accept(context.Background())
+	if err != nil {
+		return err
+	}
+
+	buf, err := sess.ReceiveMessage()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Server: Got '%s'\n", string(buf))
+
+	ch := make(chan bool)
+	fmt.Printf("Server: Sending '%s'\n", string(buf))
+	err = sess.SendMessageNotify(buf, func(r bool) {
+		ch <- r
+	})
+	select {
+	case r := <-ch:
+		fmt.Printf("server got notification: %v\n", r)
+	}
+	return err
+}
+
+func clientMain() error {
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"quic-echo-example"},
+	}
+	session, err := quic.DialAddr(addr, tlsConf, &quic.Config{EnableDatagrams: true})
+	if err != nil {
+		return err
+	}
+	defer session.CloseWithError(0, "")
+
+	ch := make(chan bool)
+	fmt.Printf("Client: Sending '%s'\n", message)
+	err = session.SendMessageNotify([]byte(message), func(r bool) {
+		ch <- r
+	})
+	if err != nil {
+		return err
+	}
+	select {
+	case r := <-ch:
+		fmt.Printf("client got notification: %v\n", r)
+	}
+
+	buf, err := session.ReceiveMessage()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Client: Got '%s'\n", buf)
+	return nil
+}
+
+// A wrapper for io.Writer that also logs the message.
+type loggingWriter struct{ io.Writer }
+
+func (w loggingWriter) Write(b []byte) (int, error) {
+	fmt.Printf("Server: Got '%s'\n", string(b))
+	return w.Writer.Write(b)
+}
+
+// Setup a bare-bones TLS config for the server
+func generateTLSConfig() *tls.Config {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		panic(err)
+	}
+	template := x509.Certificate{SerialNumber: big.NewInt(1)}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+
+	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		panic(err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{tlsCert},
+		NextProtos:   []string{"quic-echo-example"},
+	}
+}
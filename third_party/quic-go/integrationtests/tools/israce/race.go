@@ -0,0 +1,7 @@
+//go:build race
+// +build race
+
+package israce
+
+// Enabled reports if the race detector is enabled.
+const Enabled = true